@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/logging"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/tui"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+)
+
+// liveProgress is set once the run browser was shown; copies then draw a
+// progress bar on the terminal below their output.
+var liveProgress bool
+
+// useBrowser reports whether process should show the full-screen run browser
+// rather than the numbered prompt.
+func useBrowser() bool {
+	return !processNoTUI && tui.IsTerminal(os.Stdin) && tui.IsTerminal(os.Stdout)
+}
+
+// browseRuns shows the run browser and returns the chosen runs, with the
+// biosamples checked in each run that was not taken as a whole. It returns
+// ok=false if the terminal could not be opened, so that the caller can fall
+// back to the prompt.
+func browseRuns(cmd *cobra.Command, rootDir string, runs []*metadata.RunInfo) (selected []*metadata.RunInfo, samples map[string][]string, ok bool, err error) {
+	browser := &tui.Browser{
+		Title: "Runs in " + rootDir,
+		Items: make([]tui.Item, len(runs)),
+		Load: func(ctx context.Context, i int) tui.Detail {
+			return runDetailPane(ctx, rootDir, runs[i])
+		},
+	}
+	now := time.Now()
+	for i, run := range runs {
+		names := make([]string, 0, run.BioSampleCount())
+		for name := range run.BioSampleNames {
+			names = append(names, name)
+		}
+		browser.Items[i] = tui.Item{
+			Name:     run.Name,
+			Status:   string(run.Status),
+			Info:     fmt.Sprintf("%s, %d biosamples", startedLabel(run, now), run.BioSampleCount()),
+			Keywords: strings.Join(names, " "),
+			Disabled: run.Status == metadata.RunPending,
+		}
+	}
+
+	term, err := tui.Open(os.Stdin, os.Stdout)
+	if err != nil {
+		logging.Debugf("run browser unavailable: %v", err)
+		return nil, nil, false, nil
+	}
+	choices, err := browser.Run(cmd.Context(), term)
+	if closeErr := term.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("restoring terminal: %w", closeErr)
+	}
+	if err != nil {
+		return nil, nil, true, err
+	}
+
+	liveProgress = true
+	samples = make(map[string][]string)
+	for _, c := range choices {
+		run := runs[c.Index]
+		selected = append(selected, run)
+		if c.Samples == nil {
+			fmt.Printf("Selected run: %s\n", run.Name)
+			continue
+		}
+		samples[run.Name] = c.Samples
+		fmt.Printf("Selected run: %s (%d of %d biosamples: %s)\n", run.Name, len(c.Samples),
+			run.BioSampleCount(), strings.Join(c.Samples, ", "))
+	}
+	return selected, samples, true, nil
+}
+
+// runDetailPane describes run for the browser: dates, size and cells, and its
+// biosamples with barcodes and the size of their HiFi reads. Warnings are shown
+// in the pane, as the terminal belongs to the browser.
+func runDetailPane(ctx context.Context, rootDir string, run *metadata.RunInfo) tui.Detail {
+	details, err := newRunDetails(ctx, rootDir, run)
+	if err != nil {
+		return tui.Detail{Lines: []string{"Error: " + err.Error()}}
+	}
+
+	var d tui.Detail
+	for _, w := range details.warnings {
+		d.Lines = append(d.Lines, "Warning: "+w)
+	}
+	if details.Started != nil {
+		started := ui.FormatTime(*details.Started)
+		if details.DateInferred {
+			started = "~" + ui.FormatDate(*details.Started) + " (from run name)"
+		}
+		d.Lines = append(d.Lines, "Started: "+started)
+	}
+	if details.Created != nil {
+		d.Lines = append(d.Lines, "Created: "+ui.FormatTime(*details.Created))
+	}
+	if details.SizeBytes > 0 {
+		d.Lines = append(d.Lines, "Size: "+ui.FormatBytes(details.SizeBytes))
+	}
+	pending := countPending(details)
+	d.Lines = append(d.Lines, fmt.Sprintf("Cells: %d complete, %d pending", details.Cells-pending, pending))
+
+	type sampleInfo struct {
+		barcodes []string
+		size     int64
+		missing  bool
+	}
+	bySample := make(map[string]*sampleInfo)
+	var names []string
+	for _, c := range details.CellDetails {
+		line := fmt.Sprintf("  %s  %s", valueOrUnknown(c.Well), valueOrUnknown(c.Movie))
+		if c.Instrument != "" {
+			line += "  " + c.Instrument
+		}
+		if c.Status == string(metadata.RunPending) {
+			line += "  (pending)"
+		}
+		if len(c.Warnings) > 0 {
+			line += fmt.Sprintf("  %d warnings", len(c.Warnings))
+		}
+		d.Lines = append(d.Lines, line)
+
+		for _, b := range c.BioSamples {
+			s, ok := bySample[b.Name]
+			if !ok {
+				s = &sampleInfo{}
+				bySample[b.Name] = s
+				names = append(names, b.Name)
+			}
+			if b.Barcode != "" {
+				s.barcodes = append(s.barcodes, b.Barcode)
+			}
+			if b.BAM == nil || !b.BAM.Exists || !b.PBI.Exists {
+				s.missing = true
+			} else {
+				s.size += b.BAM.Size + b.PBI.Size
+			}
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		s := bySample[name]
+		info := strings.Join(s.barcodes, ", ")
+		if s.missing {
+			info += "  MISSING FILES"
+		} else {
+			info += "  " + ui.FormatBytes(s.size)
+		}
+		d.Samples = append(d.Samples, tui.Sample{Name: name, Info: strings.TrimSpace(info)})
+	}
+	return d
+}
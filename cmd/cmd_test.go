@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+// testResult returns a copy result with one mapping per error; nil errors are delivered mappings.
+func testResult(interrupted bool, errs ...error) *copyfiles.CopyResult {
+	result := &copyfiles.CopyResult{Interrupted: interrupted}
+	for i, err := range errs {
+		m := &fileops.FileMapping{BioSample: fmt.Sprintf("S%d", i+1)}
+		result.Mappings = append(result.Mappings, &copyfiles.MappingResult{Mapping: m, Err: err, Started: true})
+	}
+	return result
+}
+
+// testFailure returns the *copyFailure reported for a session whose mappings ended with errs.
+func testFailure(interrupted bool, errs ...error) error {
+	result := testResult(interrupted, errs...)
+	return reportCopyResult(result, result.Err())
+}
+
+func TestExitCode(t *testing.T) {
+	failed := errors.New("disk full")
+	missing := fmt.Errorf("%w: no such file", copyfiles.ErrSourceMissing)
+	mismatch := fmt.Errorf("%w: md5 mismatch", copyfiles.ErrVerification)
+	interrupted := fmt.Errorf("%w: rclone stopped", copyfiles.ErrInterrupted)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitOK},
+		{"other error", errors.New("invalid --jobs"), exitError},
+		{"cancelled", fmt.Errorf("scanning runs: %w", context.Canceled), exitInterrupted},
+		{"interrupted before verification", testFailure(true, nil, mismatch, interrupted), exitInterrupted},
+		{"verification before missing source", testFailure(false, missing, mismatch), exitVerificationMismatch},
+		{"missing source before partial", testFailure(false, nil, missing, failed), exitSourceMissing},
+		{"missing source before nothing copied", testFailure(false, missing, failed), exitSourceMissing},
+		{"partial", testFailure(false, nil, failed), exitPartialFailure},
+		{"nothing copied", testFailure(false, failed, failed), exitNothingCopied},
+		{"runs partial", &runsFailure{delivered: 1, failed: 1, err: failed}, exitPartialFailure},
+		{"runs nothing delivered", &runsFailure{failed: 2, err: failed}, exitNothingCopied},
+		{"runs count before copy failure", &runsFailure{delivered: 1, failed: 1, err: testFailure(false, failed)}, exitPartialFailure},
+		{"copy failure of one run", fmt.Errorf("run R1: %w", testFailure(false, nil, failed)), exitPartialFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Fatalf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReportCopyResult(t *testing.T) {
+	if err := reportCopyResult(testResult(false, nil, nil), nil); err != nil {
+		t.Fatalf("expected no error for a complete delivery, got %v", err)
+	}
+	scanErr := errors.New("no valid HiFi files identified")
+	if err := reportCopyResult(nil, scanErr); err != scanErr {
+		t.Fatalf("expected the error to pass through without a result, got %v", err)
+	}
+
+	err := testFailure(false, nil, fmt.Errorf("%w: gone", copyfiles.ErrSourceMissing))
+	var failure *copyFailure
+	if !errors.As(err, &failure) || failure.Error() != "1 of 2 biosamples failed to copy" {
+		t.Fatalf("expected a *copyFailure, got %v", err)
+	}
+	if !errors.Is(err, copyfiles.ErrSourceMissing) {
+		t.Fatalf("expected the mapping errors to be wrapped, got %v", err)
+	}
+	if err := testFailure(true, nil, copyfiles.ErrInterrupted); err.Error() != "copy interrupted: 1 of 2 biosamples delivered" {
+		t.Fatalf("unexpected interrupted error %q", err)
+	}
+}
+
+func TestReportRunsJoinsRunErrors(t *testing.T) {
+	outcome := func(name string, err error) *runOutcome {
+		o := &runOutcome{run: &metadata.RunInfo{Name: name}}
+		o.setResult(err)
+		return o
+	}
+	tests := []struct {
+		name     string
+		outcomes []*runOutcome
+		ctxErr   error
+		want     int
+	}{
+		{"all delivered", []*runOutcome{outcome("R1", nil), outcome("R2", nil)}, nil, exitOK},
+		{"one run failed", []*runOutcome{outcome("R1", nil), outcome("R2", testFailure(false, errors.New("disk full")))}, nil, exitPartialFailure},
+		{"every run failed", []*runOutcome{outcome("R1", errors.New("no valid HiFi files identified")), outcome("R2", testFailure(false, errors.New("disk full")))}, nil, exitNothingCopied},
+		{"verification in a later run", []*runOutcome{
+			outcome("R1", testFailure(false, nil, fmt.Errorf("%w: gone", copyfiles.ErrSourceMissing))),
+			outcome("R2", testFailure(false, fmt.Errorf("%w: md5 mismatch", copyfiles.ErrVerification))),
+		}, nil, exitVerificationMismatch},
+		{"not started after an interrupt", []*runOutcome{outcome("R1", nil), {run: &metadata.RunInfo{Name: "R2"}, status: "not started"}}, context.Canceled, exitInterrupted},
+	}
+	for _, tt := range tests {
+		if got := exitCode(reportRuns("TEST", tt.outcomes, tt.ctxErr)); got != tt.want {
+			t.Fatalf("%s: exit code %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 9, 22, 12, 0, 0, 0, time.Local)
+	tests := map[string]time.Time{
+		"":                          {},
+		"7d":                        time.Date(2025, 9, 15, 12, 0, 0, 0, time.Local),
+		" 0d ":                      now,
+		"48h":                       time.Date(2025, 9, 20, 12, 0, 0, 0, time.Local),
+		"90m":                       time.Date(2025, 9, 22, 10, 30, 0, 0, time.Local),
+		"2025-09-01":                time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local),
+		"2025-09-01T08:30":          time.Date(2025, 9, 1, 8, 30, 0, 0, time.Local),
+		"2025-09-01T08:30:00+02:00": time.Date(2025, 9, 1, 6, 30, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := parseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("parseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"yesterday", "-7d", "-48h", "7w", "2025-13-01", "22.09.2025"} {
+		if _, err := parseSince(in, now); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"3", []int{2}},
+		{"1,3-5", []int{0, 2, 3, 4}},
+		{" 1 , 3 - 4 ", []int{0, 2, 3}},
+		{"2,1,2", []int{1, 0}},
+		{"3-5,4,1-3", []int{2, 3, 4, 0, 1}},
+		{"5-5,", []int{4}},
+	}
+	for _, tt := range tests {
+		if !isSelection(tt.input) {
+			t.Fatalf("isSelection(%q) = false", tt.input)
+		}
+		got, err := parseSelection(tt.input, 5)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Fatalf("parseSelection(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	for _, input := range []string{"0", "6", "4-6", "5-3", "-1", "1--2", "1-", ",", " , "} {
+		if _, err := parseSelection(input, 5); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+	for _, input := range []string{"", "a", "1;2", "r84001", "1.5", "all"} {
+		if isSelection(input) {
+			t.Fatalf("isSelection(%q) = true, want false", input)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/delivery"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/journal"
+	"github.com/schnurbe/revio-copy/pkg/logging"
+	"github.com/schnurbe/revio-copy/pkg/tui"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+)
+
+// newCopier builds the copy backend selected by the current flags.
+func newCopier() (copyfiles.Copier, error) {
+	hashes, err := copyfiles.ParseHashAlgorithms(flags.GetChecksums())
+	if err != nil {
+		return nil, err
+	}
+	sums, err := sumAlgorithms()
+	if err != nil {
+		return nil, err
+	}
+	// Compute the checksum file digests while copying rather than in a second pass
+	for _, algo := range sums {
+		if !slices.Contains(hashes, algo) {
+			hashes = append(hashes, algo)
+		}
+	}
+	if flags.GetJobs() < 1 {
+		return nil, fmt.Errorf("--jobs must be at least 1, got %d", flags.GetJobs())
+	}
+	limit, err := copyfiles.ParseByteSize(flags.GetBandwidthLimit())
+	if err != nil {
+		return nil, fmt.Errorf("invalid --bwlimit: %w", err)
+	}
+	backend := flags.GetCopyBackend()
+	if len(sums) > 0 && strings.EqualFold(strings.TrimSpace(backend), copyfiles.BackendAuto) {
+		// rclone does not report its digests, so checksum files would read every file a second time
+		backend = copyfiles.BackendNative
+	}
+	return copyfiles.NewCopier(backend, copyfiles.CopierOptions{
+		Hashes:  hashes,
+		Verify:  flags.GetVerifyCopies(),
+		Verbose: flags.GetDebugMode(),
+		Jobs:    flags.GetJobs(),
+		Limiter: copyfiles.NewRateLimiter(limit),
+	})
+}
+
+// validateCopyFlags checks the identification and copy flags before any run is
+// scanned, and that the copy backend is usable unless this is a dry run.
+func validateCopyFlags() error {
+	if _, err := identifyOptions(""); err != nil {
+		return err
+	}
+	backend, err := newCopier()
+	if err != nil {
+		return err
+	}
+	if _, err := copyfiles.ParseSpaceMargin(flags.GetSpaceMargin()); err != nil {
+		return fmt.Errorf("invalid --space-margin: %w", err)
+	}
+	if !flags.GetDryRunMode() {
+		return backend.Available()
+	}
+	return nil
+}
+
+// stateDir returns the directory holding the manifest and journals for outputDir.
+func stateDir(outputDir string) string {
+	if dir := flags.GetStateDir(); dir != "" {
+		return dir
+	}
+	return copyfiles.DefaultStateDir(outputDir)
+}
+
+// newFileCopier creates a FileCopier for outputDir configured from the current flags.
+func newFileCopier(outputDir string, dryRun, verbose bool) (*copyfiles.FileCopier, error) {
+	backend, err := newCopier()
+	if err != nil {
+		return nil, err
+	}
+	onInterrupt, err := copyfiles.ParseInterruptMode(flags.GetInterruptMode())
+	if err != nil {
+		return nil, err
+	}
+	logging.Debugf("using %s copy backend", backend.Name())
+	copier := copyfiles.NewFileCopier(backend, dryRun, verbose)
+	copier.Jobs = flags.GetJobs()
+	copier.OnInterrupt = onInterrupt
+	if err := configureResume(copier, outputDir); err != nil {
+		return nil, err
+	}
+	return copier, nil
+}
+
+// configureResume attaches the output directory's manifest to copier and enables resume mode when requested.
+func configureResume(copier *copyfiles.FileCopier, outputDir string) error {
+	if copier.DryRun {
+		return nil
+	}
+	manifest, err := copyfiles.LoadManifest(copyfiles.ManifestPath(stateDir(outputDir)))
+	if err != nil {
+		return err
+	}
+	copier.Manifest = manifest
+	copier.Resume = flags.GetResumeMode()
+	if hashes, err := copyfiles.ParseHashAlgorithms(flags.GetChecksums()); err == nil && len(hashes) > 0 {
+		copier.ResumeHash = hashes[0]
+	}
+	return nil
+}
+
+// runCopySession copies mappings and records the session in a new journal.
+// filter describes the biosamples and cells the mappings are limited to, if any.
+// Dry runs are not journaled. Failed biosamples are reported and returned as a *copyFailure.
+func runCopySession(ctx context.Context, copier *copyfiles.FileCopier, runName, filter, outputDir string, mappings []*fileops.FileMapping) error {
+	if err := preflight(mappings, copier.DryRun, flags.GetResumeMode()); err != nil {
+		return err
+	}
+	if copier.DryRun {
+		return reportCopyResult(copyMappings(ctx, copier, mappings))
+	}
+
+	j, err := journal.Create(stateDir(outputDir), journal.NewSessionID(runName, time.Now()))
+	if err != nil {
+		return err
+	}
+	if err := j.Plan(runName, outputDir, filter, mappings); err != nil {
+		j.Close(0, 0, 0)
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	fmt.Printf("Journal: %s\n", j.Path())
+
+	return copyWithJournal(ctx, copier, j, runName, filter, outputDir, mappings)
+}
+
+// copyWithJournal copies mappings with j as recorder, writes the checksum files
+// of the delivery in outputDir and closes j with the session totals. A session
+// that delivered every mapping of a whole run (no filter) is added to the delivery registry.
+func copyWithJournal(ctx context.Context, copier *copyfiles.FileCopier, j *journal.Journal, runName, filter, outputDir string, mappings []*fileops.FileMapping) error {
+	copier.Recorder = j
+	result, copyErr := copyMappings(ctx, copier, mappings)
+	sumsErr := writeChecksumFiles(outputDir, result, copier.Manifest)
+	copied, skipped, failed := copier.Totals()
+	if err := j.Close(copied, skipped, failed); err != nil && copyErr == nil {
+		return fmt.Errorf("closing journal: %w", err)
+	}
+	if err := reportCopyResult(result, copyErr); err != nil {
+		return err
+	}
+	if filter != "" {
+		fmt.Printf("Only part of run %s was delivered (%s); it is not recorded as delivered.\n", runName, filter)
+	} else if err := recordDelivery(runName, outputDir, j.Path(), result); err != nil {
+		ui.Yellow("Warning: could not record delivery: %v\n", err)
+	}
+	return sumsErr
+}
+
+// recordDelivery adds a complete delivery of runName to the registry in the state directory of outputDir.
+func recordDelivery(runName, outputDir, journalPath string, result *copyfiles.CopyResult) error {
+	if runName == "" || result == nil {
+		return nil
+	}
+	registry, err := delivery.LoadRegistry(delivery.RegistryPath(stateDir(outputDir)))
+	if err != nil {
+		return err
+	}
+	rec := delivery.Record{Run: runName, OutputDir: outputDir, Journal: journalPath, Mappings: len(result.Mappings),
+		Bytes: result.Bytes + result.SkippedBytes}
+	for _, m := range result.Mappings {
+		rec.Files += len(m.Files)
+	}
+	return registry.Add(rec)
+}
+
+// sumAlgorithms returns the algorithms of the checksum files to write (--sums).
+func sumAlgorithms() ([]copyfiles.HashAlgorithm, error) {
+	value := flags.GetSumFiles()
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil, nil
+	}
+	algos, err := copyfiles.ParseHashAlgorithms(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sums: %w", err)
+	}
+	return algos, nil
+}
+
+// writeChecksumFiles writes the MD5SUMS-style files for the delivered mappings of result.
+func writeChecksumFiles(outputDir string, result *copyfiles.CopyResult, manifest *copyfiles.Manifest) error {
+	algos, err := sumAlgorithms()
+	if err != nil || len(algos) == 0 || result == nil || result.Succeeded() == 0 {
+		return err
+	}
+	written, err := copyfiles.WriteChecksumFiles(outputDir, result, algos, manifest)
+	if len(written) > 0 {
+		fmt.Printf("Checksum files updated: %d (delivery: %s)\n", len(written),
+			strings.Join(deliverySumsFiles(outputDir, algos), ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("writing checksum files: %w", err)
+	}
+	return nil
+}
+
+// deliverySumsFiles returns the delivery-level checksum file paths in outputDir.
+func deliverySumsFiles(outputDir string, algos []copyfiles.HashAlgorithm) []string {
+	paths := make([]string, len(algos))
+	for i, algo := range algos {
+		paths[i] = filepath.Join(outputDir, copyfiles.SumsFileName(algo))
+	}
+	return paths
+}
+
+// copyMappings copies mappings, letting a second interrupt abort copies in progress.
+// After the run browser, a live progress bar is drawn below the copy output.
+func copyMappings(ctx context.Context, copier *copyfiles.FileCopier, mappings []*fileops.FileMapping) (*copyfiles.CopyResult, error) {
+	interrupts.setAbort(copier.Abort)
+	defer interrupts.setAbort(nil)
+	if liveProgress && !copier.DryRun {
+		progress := tui.NewProgress(os.Stdout, mappings, copier.Recorder)
+		copier.Recorder, copier.Out = progress, progress
+		defer progress.Finish()
+	}
+	return copier.CopyAllFileMappings(ctx, mappings)
+}
+
+// preflight prints the free-space and permission checks for mappings. Failed
+// checks stop the copy unless --force is given; dry runs only report them.
+// With skipExisting, destinations already present are not counted as data to copy.
+func preflight(mappings []*fileops.FileMapping, dryRun, skipExisting bool) error {
+	margin, err := copyfiles.ParseSpaceMargin(flags.GetSpaceMargin())
+	if err != nil {
+		return fmt.Errorf("invalid --space-margin: %w", err)
+	}
+	report := copyfiles.Preflight(mappings, margin, skipExisting)
+
+	ui.Bold("\nPre-flight checks:\n")
+	if skipExisting {
+		fmt.Printf("  Data to copy: %s (%s of %s already present)\n",
+			ui.FormatBytes(report.Total-report.Present), ui.FormatBytes(report.Present), ui.FormatBytes(report.Total))
+	} else {
+		fmt.Printf("  Data to copy: %s\n", ui.FormatBytes(report.Total))
+	}
+	for _, v := range report.Volumes {
+		free := "unknown"
+		if v.Free >= 0 {
+			free = ui.FormatBytes(v.Free)
+		}
+		line := fmt.Sprintf("  %s: needs %s + %s margin, %s free\n",
+			v.Path, ui.FormatBytes(v.Needed), ui.FormatBytes(v.Margin), free)
+		if v.OK() {
+			fmt.Print(line)
+		} else {
+			ui.Red(line)
+		}
+	}
+	if report.OK() {
+		ui.Green("  All checks passed.\n")
+		return nil
+	}
+	for _, problem := range report.Problems {
+		ui.Red("  ✗ %v\n", problem)
+	}
+	switch {
+	case dryRun:
+		ui.Yellow("  A real copy would not start without --force.\n")
+		return nil
+	case flags.GetForce():
+		ui.Yellow("  --force given, copying anyway.\n")
+		return nil
+	}
+	return fmt.Errorf("pre-flight checks failed; use --force to copy anyway")
+}
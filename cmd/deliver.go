@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+)
+
+// deliverRun identifies the files of run and copies them to outputDir without
+// prompting, as process does after its report. Runs with biosamples that could
+// not be resolved or with missing HiFi files are not copied.
+func deliverRun(ctx context.Context, run *metadata.RunInfo, outputDir string) error {
+	opts, err := identifyOptions(outputDir)
+	if err != nil {
+		return err
+	}
+	mappings, err := fileops.IdentifyAllHiFiFiles(ctx, run.Cells, opts)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return fmt.Errorf("file identification failed for run %s: %w", run.Name, err)
+	}
+
+	hifiMappings, extraMappings := splitByClass(mappings)
+	missing := 0
+	for _, m := range hifiMappings {
+		for _, f := range m.Files() {
+			if _, err := os.Stat(f.Source); err != nil {
+				ui.Red("  Missing source file: %s\n", f.Source)
+				missing++
+			}
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%w: %d of %d files not found", copyfiles.ErrSourceMissing, missing, countFiles(hifiMappings))
+	}
+	fmt.Printf("Run %s: %d biosamples", run.Name, len(hifiMappings))
+	if len(extraMappings) > 0 {
+		fmt.Printf(" and %d optional files", countFiles(extraMappings))
+	}
+	fmt.Printf(" -> %s\n", outputDir)
+
+	copier, err := newFileCopier(outputDir, flags.GetDryRunMode(), flags.GetDebugMode())
+	if err != nil {
+		return err
+	}
+	return runCopySession(ctx, copier, run.Name, "", outputDir, mappings)
+}
+
+// runOutcome is the result of one run in a session that delivers several runs.
+type runOutcome struct {
+	run    *metadata.RunInfo
+	err    error
+	status string // delivered, failed, simulated or not started
+}
+
+// runsFailure is returned when some runs of a session were not delivered.
+type runsFailure struct {
+	delivered, failed int
+	err               error
+}
+
+func (e *runsFailure) Error() string {
+	return fmt.Sprintf("%d of %d runs failed to deliver", e.failed, e.delivered+e.failed)
+}
+
+func (e *runsFailure) Unwrap() error { return e.err }
+
+// setResult records the outcome of delivering o.run.
+func (o *runOutcome) setResult(err error) {
+	o.err = err
+	switch {
+	case err != nil:
+		o.status = "failed"
+	case flags.GetDryRunMode():
+		o.status = "simulated"
+	default:
+		o.status = "delivered"
+	}
+}
+
+// reportRuns prints the combined summary of a session that delivered several
+// runs and returns a *runsFailure when a run failed or was not started.
+func reportRuns(title string, outcomes []*runOutcome, ctxErr error) error {
+	ui.Bold("\n=============== %s ===============\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tRESULT\tDETAIL")
+	failure := &runsFailure{}
+	var errs []error
+	for _, o := range outcomes {
+		detail := ""
+		if o.err != nil {
+			detail = strings.ReplaceAll(o.err.Error(), "\n", "; ")
+			errs = append(errs, fmt.Errorf("run %s: %w", o.run.Name, o.err))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", o.run.Name, o.status, detail)
+		switch o.status {
+		case "delivered", "simulated":
+			failure.delivered++
+		default:
+			failure.failed++
+		}
+	}
+	w.Flush()
+	if ctxErr != nil {
+		errs = append(errs, ctxErr)
+	}
+	if failure.failed == 0 {
+		ui.Green("All %d runs delivered.\n", failure.delivered)
+		return nil
+	}
+	ui.Red("%d of %d runs were not delivered.\n", failure.failed, len(outcomes))
+	failure.err = errors.Join(errs...)
+	return failure
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+)
+
+// Process exit codes. When several failure classes apply, the most specific
+// wins: interruption, verification mismatch, missing source, nothing copied.
+const (
+	exitOK                   = 0
+	exitError                = 1   // Usage, configuration or any other error
+	exitPartialFailure       = 2   // Some biosamples were delivered, others failed
+	exitNothingCopied        = 3   // No biosample was delivered
+	exitSourceMissing        = 4   // A source file did not exist
+	exitVerificationMismatch = 5   // A copy did not match its source
+	exitInterrupted          = 130 // Stopped by SIGINT/SIGTERM (128 + SIGINT)
+)
+
+// copyFailure is returned when a copy session finished with failed biosamples.
+type copyFailure struct {
+	result *copyfiles.CopyResult
+	err    error
+}
+
+func (e *copyFailure) Error() string {
+	if e.result.Interrupted {
+		return fmt.Sprintf("copy interrupted: %d of %d biosamples delivered",
+			e.result.Succeeded(), len(e.result.Mappings))
+	}
+	return fmt.Sprintf("%d of %d biosamples failed to copy",
+		len(e.result.Failures()), len(e.result.Mappings))
+}
+
+func (e *copyFailure) Unwrap() error { return e.err }
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, copyfiles.ErrInterrupted):
+		return exitInterrupted
+	case errors.Is(err, copyfiles.ErrVerification):
+		return exitVerificationMismatch
+	case errors.Is(err, copyfiles.ErrSourceMissing):
+		return exitSourceMissing
+	}
+	var runsErr *runsFailure
+	if errors.As(err, &runsErr) {
+		if runsErr.delivered == 0 {
+			return exitNothingCopied
+		}
+		return exitPartialFailure
+	}
+	var failure *copyFailure
+	if errors.As(err, &failure) {
+		if failure.result.Succeeded() == 0 {
+			return exitNothingCopied
+		}
+		return exitPartialFailure
+	}
+	return exitError
+}
+
+// reportCopyResult prints the outcome of a copy session and returns a
+// *copyFailure if any biosample was not delivered.
+func reportCopyResult(result *copyfiles.CopyResult, err error) error {
+	if result == nil {
+		return err
+	}
+	if err == nil {
+		return nil
+	}
+	if result.Interrupted {
+		reportInterrupted(result)
+	} else {
+		ui.Red("\nFailed biosamples:\n")
+		for _, m := range result.Failures() {
+			ui.Red("  - %s: %v\n", m.Mapping.Label(), m.Err)
+		}
+	}
+	fmt.Printf("Delivered %d of %d biosamples (%d files copied, %d already present, %d failed; %.2f GB transferred)\n",
+		result.Succeeded(), len(result.Mappings), result.Copied, result.Skipped, result.Failed,
+		float64(result.Bytes)/(1024*1024*1024))
+	return &copyFailure{result: result, err: err}
+}
+
+// reportInterrupted lists which biosamples were delivered before the session was interrupted.
+func reportInterrupted(result *copyfiles.CopyResult) {
+	var delivered, aborted, failed, notStarted []string
+	for _, m := range result.Mappings {
+		switch {
+		case m.OK():
+			delivered = append(delivered, m.Mapping.Label())
+		case !m.Started:
+			notStarted = append(notStarted, m.Mapping.Label())
+		case errors.Is(m.Err, copyfiles.ErrInterrupted):
+			aborted = append(aborted, m.Mapping.Label())
+		default:
+			failed = append(failed, m.Mapping.Label())
+		}
+	}
+	ui.Yellow("\nCopy interrupted.\n")
+	for _, group := range []struct {
+		label   string
+		samples []string
+	}{
+		{"Delivered", delivered},
+		{"Aborted", aborted},
+		{"Failed", failed},
+		{"Not started", notStarted},
+	} {
+		if len(group.samples) > 0 {
+			fmt.Printf("  %s (%d): %s\n", group.label, len(group.samples), strings.Join(group.samples, ", "))
+		}
+	}
+	fmt.Println("Run 'revio-copy resume --output <dir>' to continue the session.")
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --format.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatTSV   = "tsv"
+	formatYAML  = "yaml"
+)
+
+var listFormat string
+
+// runListing is the machine-readable summary of one run.
+type runListing struct {
+	Name         string     `json:"name" yaml:"name"`
+	Status       string     `json:"status" yaml:"status"`
+	Created      *time.Time `json:"created,omitempty" yaml:"created,omitempty"`
+	Started      *time.Time `json:"started,omitempty" yaml:"started,omitempty"`
+	DateInferred bool       `json:"date_inferred,omitempty" yaml:"date_inferred,omitempty"`
+	Cells        int        `json:"cells" yaml:"cells"`
+	BioSamples   int        `json:"biosamples" yaml:"biosamples"`
+	SizeBytes    int64      `json:"size_bytes" yaml:"size_bytes"`
+
+	warnings []string // Problems met while summarizing, e.g. an unreadable size
+}
+
+// listCmd prints the runs of a directory without prompting
+var listCmd = &cobra.Command{
+	Use:   "list [directory]",
+	Short: "List the runs of a directory",
+	Long: `List every run found in the directory, newest first, with its status, dates, number of
+cells and biosamples, and the size of its cell directories. Nothing is read from stdin, so the
+output can be consumed by scripts: --format json, tsv or yaml print machine-readable output.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkFormat(listFormat, formatTable, formatJSON, formatTSV, formatYAML)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runs, err := metadata.GetAllRuns(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		listings := make([]runListing, 0, len(runs))
+		for _, run := range runs {
+			listing, err := newRunListing(cmd.Context(), run)
+			if err != nil {
+				return err
+			}
+			listing.printWarnings()
+			listings = append(listings, listing)
+		}
+		return writeRunList(os.Stdout, listFormat, listings)
+	},
+}
+
+// checkFormat validates a --format value against the formats a command supports.
+func checkFormat(format string, supported ...string) error {
+	for _, f := range supported {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(supported, ", "))
+}
+
+// newRunListing summarizes run. Sizes that cannot be determined are reported
+// as 0, with a warning in the listing.
+func newRunListing(ctx context.Context, run *metadata.RunInfo) (runListing, error) {
+	size, err := run.DataSize(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return runListing{}, ctxErr
+	}
+	listing := runListing{
+		Name:         run.Name,
+		Status:       string(run.Status),
+		Created:      timeOrNil(run.CreatedDate),
+		Started:      timeOrNil(run.StartedDate),
+		DateInferred: run.DateInferred,
+		Cells:        len(run.Cells),
+		BioSamples:   run.BioSampleCount(),
+		SizeBytes:    size,
+	}
+	if err != nil {
+		listing.warnings = append(listing.warnings, fmt.Sprintf("size of run %s: %v", run.Name, err))
+	}
+	return listing, nil
+}
+
+// printWarnings writes the warnings of l to stderr, keeping them out of machine-readable output.
+func (l runListing) printWarnings() {
+	for _, w := range l.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+}
+
+// writeRunList writes the listings in the given format.
+func writeRunList(w io.Writer, format string, listings []runListing) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	case formatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(listings); err != nil {
+			return err
+		}
+		return enc.Close()
+	case formatTSV:
+		fmt.Fprintln(w, "name\tstatus\tcreated\tstarted\tdate_inferred\tcells\tbiosamples\tsize_bytes")
+		for _, l := range listings {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%d\t%d\t%d\n", l.Name, l.Status, formatRFC3339(l.Created),
+				formatRFC3339(l.Started), l.DateInferred, l.Cells, l.BioSamples, l.SizeBytes)
+		}
+		return nil
+	default:
+		now := time.Now()
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "RUN\tSTATUS\tSTARTED\tCREATED\tCELLS\tBIOSAMPLES\tSIZE")
+		for _, l := range listings {
+			started := "-"
+			if l.Started != nil {
+				started = ui.FormatTime(*l.Started)
+				if l.DateInferred {
+					started = "~" + ui.FormatDate(*l.Started)
+				}
+				started += " (" + ui.RelativeAge(*l.Started, now) + ")"
+			}
+			created := "-"
+			if l.Created != nil {
+				created = ui.FormatTime(*l.Created)
+			}
+			size := "-"
+			if l.SizeBytes > 0 {
+				size = ui.FormatBytes(l.SizeBytes)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", l.Name, l.Status, started, created,
+				l.Cells, l.BioSamples, size)
+		}
+		return tw.Flush()
+	}
+}
+
+// timeOrNil returns nil for the zero time so that unknown dates are omitted.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// formatRFC3339 formats t for TSV output; unknown dates are empty.
+func formatRFC3339(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func init() {
+	listCmd.Flags().StringVar(&listFormat, "format", formatTable, "output format: table, json, tsv or yaml")
+	rootCmd.AddCommand(listCmd)
+}
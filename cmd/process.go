@@ -2,12 +2,14 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/schnurbe/revio-copy/pkg/copyfiles"
 	"github.com/schnurbe/revio-copy/pkg/fileops"
@@ -23,13 +25,22 @@ var processCmd = &cobra.Command{
 	Use:   "process [directory]",
 	Short: "Process PacBio Revio sequencing data",
 	Long: `Process PacBio Revio sequencing data by extracting metadata information.
-If no run name is specified, you will be prompted to select from available runs.`,
+If no run name is specified, a full-screen browser shows the available runs with a fuzzy
+search, the details of the highlighted run, and checkboxes to copy only some of its biosamples.
+Without a terminal, or with --no-tui, you are prompted instead: enter one or more numbers and
+ranges (1,3-5), 'a' for all complete runs, or text to filter the list by run or biosample name.
+Several runs are identified and copied in one session with a combined report.`,
 	Args: cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		if flags.GetOutputDir() != "" && !flags.GetDryRunMode() {
-			if err := checkRcloneAvailability(); err != nil {
-				return err
-			}
+		// Validate layout templates, collision policy and filters before planning any copy
+		if _, err := identifyOptions(flags.GetOutputDir()); err != nil {
+			return err
+		}
+		if _, err := sampleFilter(); err != nil {
+			return err
+		}
+		if flags.GetOutputDir() != "" {
+			return validateCopyFlags()
 		}
 		return nil
 	},
@@ -38,7 +49,7 @@ If no run name is specified, you will be prompted to select from available runs.
 
 		// Find metadata files
 		ui.Italic("Scanning for runs in %s...\n", rootDir)
-		allRuns, err := metadata.GetAllRuns(rootDir)
+		allRuns, err := metadata.GetAllRuns(cmd.Context(), rootDir)
 		if err != nil {
 			return err
 		}
@@ -54,270 +65,822 @@ If no run name is specified, you will be prompted to select from available runs.
 		// 	debugf("metadata file %d: %s", i+1, file)
 		// }
 
-		// Check if a specific run was requested
-		var selectedRun *metadata.RunInfo
-		runName := flags.GetRunName()
-
-		if runName != "" {
-			// Process specific run
-			ui.Italic("Looking for run: %s\n", runName)
-			// Find the run from the list of all runs
-			for _, run := range allRuns {
-				if run.Name == runName {
-					selectedRun = run
-					break
-				}
-			}
+		// Use the run given with --run, or let the user pick one or more runs
+		selectedRuns, samples, err := selectRuns(cmd, rootDir, allRuns)
+		if err != nil {
+			return err
+		}
+		if len(selectedRuns) == 0 {
+			fmt.Println("Aborted.")
+			return nil
+		}
 
-			if selectedRun == nil {
-				return fmt.Errorf("run '%s' not found", runName)
-			}
+		for _, run := range selectedRuns {
+			printRunDetails(run, len(selectedRuns) > 1)
+		}
 
-			fmt.Printf("Found run '%s' with %d biosamples\n",
-				runName, selectedRun.BioSampleCount())
-		} else {
-			// No specific run, list available runs for selection
-			ui.Bold("Available runs (sorted by started date, newest first):\n")
-			for i, run := range allRuns {
-				var statusLabel string
-				if run.Status == metadata.RunPending {
-					statusLabel = " (pending)"
-					fmt.Printf("%d. %s - ", i+1, run.Name)
-					if run.StartedDate != "" {
-						fmt.Printf("Started: %s ", run.StartedDate)
-					} else {
-						fmt.Printf("Date unknown ")
-					}
-					fmt.Printf("(%d biosamples)", run.BioSampleCount())
-					ui.Yellow("%s\n", statusLabel)
-				} else {
-					dateStr := "Date unknown"
-					if run.StartedDate != "" {
-						dateStr = fmt.Sprintf("Started: %s", run.StartedDate)
+		// Check if an output directory was provided to identify files for copying
+		outputDir := flags.GetOutputDir()
+		if outputDir != "" {
+			ui.Italic("\nIdentifying files to copy...\n")
+
+			// Debug output dir
+			logging.Debugf("output directory: %s", outputDir)
+
+			opts, err := identifyOptions(outputDir)
+			if err != nil {
+				return err
+			}
+			if opts.Filter, err = sampleFilter(); err != nil {
+				return err
+			}
+			if opts.Filter != nil {
+				fmt.Printf("Only biosamples and cells matching: %s\n", opts.Filter)
+			}
+			if processPickSamples && samples == nil {
+				if samples, err = pickBioSamples(cmd.Context(), selectedRuns); err != nil || samples == nil {
+					if err == nil {
+						fmt.Println("Aborted.")
 					}
-					ui.Green("%d. %s - %s (%d biosamples)\n",
-						i+1, run.Name, dateStr, run.BioSampleCount())
+					return err
 				}
 			}
 
-			// Prompt for run selection
-			var selected int
-			for {
-				selected = promptForSelection("Select a run by number", len(allRuns))
-				if selected == -1 { // Error
-					return fmt.Errorf("invalid selection")
+			plans := make([]*runPlan, 0, len(selectedRuns))
+			for _, run := range selectedRuns {
+				runOpts := opts
+				if names, ok := samples[run.Name]; ok {
+					runOpts.Filter = opts.Filter.Only(names)
 				}
-				if selected == -2 { // Quit
-					fmt.Println("Aborted.")
-					return nil
+				for i, cell := range run.Cells {
+					logging.Debugf("run %s cell %d path=%s biosamples=%v", run.Name, i+1, cell.FilePath, cell.BioSamples)
 				}
 
-				if allRuns[selected].Status == metadata.RunPending {
-					ui.Yellow("This run is pending and cannot be selected. Please choose another run.\n")
-				} else {
-					break
+				// Identify files to copy
+				logging.Debugf("identifying HiFi files across %d cells of run %s", len(run.Cells), run.Name)
+				mappings, err := fileops.IdentifyAllHiFiFiles(cmd.Context(), run.Cells, runOpts)
+				if ctxErr := cmd.Context().Err(); ctxErr != nil {
+					return ctxErr
 				}
+				if err != nil {
+					ui.Red("Error identifying files of run %s:\n", run.Name)
+					for _, line := range strings.Split(err.Error(), "\n") {
+						ui.Red("  - %s\n", line)
+					}
+				} else if len(mappings) == 0 && runOpts.Filter != nil {
+					ui.Yellow("No biosample or cell of run %s matches the filter (%s); it is skipped.\n",
+						run.Name, runOpts.Filter)
+				}
+				plans = append(plans, &runPlan{run: run, filter: runOpts.Filter, mappings: mappings, err: err})
+			}
+
+			invalidFileCount, hifiFileCount := 0, 0
+			if fileMappings := planMappings(plans); len(fileMappings) > 0 {
+				fmt.Printf("\nIdentified %d files to copy:\n", countFiles(fileMappings))
+				invalidFileCount, hifiFileCount = reportIdentification(plans)
 			}
 
-			selectedRun = allRuns[selected]
-			fmt.Printf("Selected run: %s\n", selectedRun.Name)
+			// Runs with missing source files or unresolved biosamples are not copied.
+			// Other selected runs are copied and the session ends as a partial failure.
+			failedRuns := failedPlans(plans)
+			switch {
+			case len(failedRuns) == len(plans) && invalidFileCount > 0:
+				ui.Red("\nCannot proceed with copying due to missing source files.\n")
+				fmt.Println("Please check the file identification report above.")
+				return fmt.Errorf("%w: %d of %d files not found", copyfiles.ErrSourceMissing,
+					invalidFileCount, hifiFileCount)
+			case len(failedRuns) == len(plans):
+				ui.Red("\nCannot proceed with copying because some biosamples could not be resolved to exactly one BAM file.\n")
+				fmt.Println("Please check the identification errors above.")
+				return fmt.Errorf("file identification failed for run %s", strings.Join(failedRuns, ", "))
+			case len(failedRuns) > 0:
+				ui.Yellow("\nSkipping %d of %d runs that cannot be copied: %s\n", len(failedRuns), len(plans),
+					strings.Join(failedRuns, ", "))
+				fmt.Println("Please check the file identification report above.")
+			}
+			if len(planMappings(plans)) > 0 {
+				if err := copyPlans(cmd.Context(), plans, outputDir); err != nil {
+					return err
+				}
+			} else if opts.Filter != nil || len(samples) > 0 {
+				ui.Red("\nNo files to copy.\n")
+				return fmt.Errorf("no biosample or cell of the selected runs matches the filter")
+			}
+		} else {
+			fmt.Printf("\nUse --output flag to identify files for copying\n")
 		}
 
-		// Print information about the selected run
-		ui.Bold("\nRun Details:\n")
-		fmt.Printf("Run Name: %s\n", selectedRun.Name)
+		if flags.GetDryRunMode() {
+			fmt.Printf("\nFile identification and dry-run complete.\n")
+		} else {
+			ui.Green("\nFile processing complete.\n")
+		}
+
+		return nil
+	},
+}
+
+// runPlan holds the files identified for one selected run.
+type runPlan struct {
+	run      *metadata.RunInfo
+	filter   *fileops.Filter // Biosamples and cells selected; nil means the whole run
+	mappings []*fileops.FileMapping
+	err      error // Biosamples that could not be resolved
+	missing  int   // HiFi source files not found
+}
+
+// problem returns why the files of the plan cannot be copied, or nil.
+func (p *runPlan) problem() error {
+	if p.missing > 0 {
+		return fmt.Errorf("%d source files not found", p.missing)
+	}
+	if p.err != nil {
+		return fmt.Errorf("file identification failed: %w", p.err)
+	}
+	return nil
+}
+
+// planMappings returns the mappings of all plans.
+func planMappings(plans []*runPlan) []*fileops.FileMapping {
+	var mappings []*fileops.FileMapping
+	for _, plan := range plans {
+		mappings = append(mappings, plan.mappings...)
+	}
+	return mappings
+}
+
+// selectRuns returns the run given with --run, or the runs picked in the run
+// browser or at the prompt. samples holds the biosamples to copy of runs of
+// which only some were checked in the browser. It returns no runs when the user quits.
+func selectRuns(cmd *cobra.Command, rootDir string, allRuns []*metadata.RunInfo) (runs []*metadata.RunInfo, samples map[string][]string, err error) {
+	runName := flags.GetRunName()
+	if runName == "" {
+		if useBrowser() {
+			runs, samples, ok, err := browseRuns(cmd, rootDir, allRuns)
+			if ok {
+				return runs, samples, err
+			}
+		}
+		runs, err := promptForRuns(cmd.Context(), allRuns)
+		return runs, nil, err
+	}
 
-		// Print started date information if available
-		if selectedRun.StartedDate != "" {
-			fmt.Printf("Run Started: %s\n", selectedRun.StartedDate)
+	ui.Italic("Looking for run: %s\n", runName)
+	for _, run := range allRuns {
+		if run.Name == runName {
+			fmt.Printf("Found run '%s' with %d biosamples\n", runName, run.BioSampleCount())
+			return []*metadata.RunInfo{run}, nil, nil
 		}
+	}
+	return nil, nil, fmt.Errorf("run '%s' not found", runName)
+}
+
+// printRunDetails prints the dates, cells and biosamples of run.
+func printRunDetails(run *metadata.RunInfo, multiple bool) {
+	if multiple {
+		ui.Bold("\n=============== %s ===============\n", run.Name)
+	}
+	ui.Bold("\nRun Details:\n")
+	fmt.Printf("Run Name: %s\n", run.Name)
 
-		fmt.Printf("Number of Unique Biosamples: %d\n\n", selectedRun.BioSampleCount())
+	// Print date information if available
+	if !run.CreatedDate.IsZero() {
+		fmt.Printf("Run Created: %s\n", ui.FormatTime(run.CreatedDate))
+	}
+	if !run.StartedDate.IsZero() {
+		fmt.Printf("Run Started: %s (%s)\n", ui.FormatTime(run.StartedDate),
+			ui.RelativeAge(run.StartedDate, time.Now()))
+	}
 
-		// Print unique biosamples
-		ui.Bold("\nUnique biosamples in this run:\n")
-		biosamples := make([]string, 0, selectedRun.BioSampleCount())
-		for biosample := range selectedRun.BioSampleNames {
-			biosamples = append(biosamples, biosample)
+	fmt.Printf("Number of Unique Biosamples: %d\n", run.BioSampleCount())
+	fmt.Printf("Number of Cells: %d\n\n", len(run.Cells))
+
+	// Print SMRT cell identity
+	if len(run.Cells) > 0 {
+		ui.Bold("\nCells in this run:\n")
+		for i, cell := range run.Cells {
+			fmt.Printf("%d. Well %s - Movie: %s\n", i+1, valueOrUnknown(cell.Cell.Position()), valueOrUnknown(cell.Cell.MovieName))
+			if cell.Cell.CellBarcode != "" {
+				fmt.Printf("    Cell barcode: %s\n", cell.Cell.CellBarcode)
+			}
+			if cell.Cell.CellIndex >= 0 {
+				fmt.Printf("    Cell index: %d\n", cell.Cell.CellIndex)
+			}
+			if instrument := cell.Cell.Instrument(); instrument != "" {
+				fmt.Printf("    Instrument: %s\n", instrument)
+			}
+			fmt.Printf("    Biosamples: %d\n", len(cell.BioSamples))
+			if cell.Stats != nil && !cell.Stats.Cell.IsZero() {
+				fmt.Printf("    HiFi: %s\n", formatReadStats(cell.Stats.Cell))
+			}
 		}
-		sort.Strings(biosamples)
-		for i, biosample := range biosamples {
+	}
+
+	// Print unique biosamples
+	ui.Bold("\nUnique biosamples in this run:\n")
+	biosamples := make([]string, 0, run.BioSampleCount())
+	for biosample := range run.BioSampleNames {
+		biosamples = append(biosamples, biosample)
+	}
+	sort.Strings(biosamples)
+	for i, biosample := range biosamples {
+		if stats, ok := run.BioSampleStats(biosample); ok {
+			fmt.Printf("%d. %s - %s\n", i+1, biosample, formatReadStats(stats))
+		} else {
 			fmt.Printf("%d. %s\n", i+1, biosample)
 		}
+	}
+}
 
-		// Check if an output directory was provided to identify files for copying
-		outputDir := flags.GetOutputDir()
-		if outputDir != "" {
-			ui.Italic("\nIdentifying files to copy...\n")
+// failedPlans returns the names of the runs whose files cannot be copied.
+func failedPlans(plans []*runPlan) []string {
+	var names []string
+	for _, plan := range plans {
+		if plan.problem() != nil {
+			names = append(names, plan.run.Name)
+		}
+	}
+	return names
+}
 
-			// Create a map of metadata files to biosamples
-			metadataFileToBiosample := make(map[string][]metadata.BioSampleInfo)
-			metadataFiles := make([]string, 0, len(selectedRun.Cells))
+// reportIdentification prints the combined file identification report and summary
+// of plans and records the missing files of each plan. It returns the number of
+// missing HiFi files and of HiFi files in total.
+func reportIdentification(plans []*runPlan) (invalidFileCount, hifiFileCount int) {
+	fileMappings := planMappings(plans)
+	hifiMappings, extraMappings := splitByClass(fileMappings)
+	ui.Bold("\n=============== FILE IDENTIFICATION REPORT ===============\n")
+
+	// Track totals for summary
+	var totalBAMSize, totalPBISize int64
+	var validFileCount int
+
+	var hifiIndex int
+	for _, plan := range plans {
+		runMappings, _ := splitByClass(plan.mappings)
+		if len(plans) > 1 {
+			ui.Bold("\n--- Run %s: %d biosamples ---\n", plan.run.Name, len(runMappings))
+		}
+		for _, mapping := range runMappings {
+			hifiIndex++
+			ui.Bold("\n[%d] Biosample: %s", hifiIndex, mapping.BioSample)
+			if mapping.MultiCell {
+				ui.Yellow(" (multi-cell)")
+			}
+			fmt.Println()
+			fmt.Printf("    Cell: %s\n", valueOrUnknown(mapping.Cell.String()))
+
+			// Check if source BAM exists and get size
+			bamInfo, bamErr := os.Stat(mapping.SourceBAM)
+			bamExists := bamErr == nil
+			bamSize := int64(0)
+			if bamExists {
+				bamSize = bamInfo.Size()
+				totalBAMSize += bamSize
+				validFileCount++
+			} else {
+				invalidFileCount++
+				plan.missing++
+			}
 
-			// Debug cell count
-			logging.Debugf("selected run has %d cells", len(selectedRun.Cells))
+			// Check if source PBI exists and get size
+			pbiInfo, pbiErr := os.Stat(mapping.SourcePBI)
+			pbiExists := pbiErr == nil
+			pbiSize := int64(0)
+			if pbiExists {
+				pbiSize = pbiInfo.Size()
+				totalPBISize += pbiSize
+				validFileCount++
+			} else {
+				invalidFileCount++
+				plan.missing++
+			}
 
-			for i, cell := range selectedRun.Cells {
-				logging.Debugf("cell %d path=%s biosamples=%v", i+1, cell.FilePath, cell.BioSamples)
-				metadataFileToBiosample[cell.FilePath] = cell.BioSamples
-				metadataFiles = append(metadataFiles, cell.FilePath)
+			// Print source file information with existence status and size
+			fmt.Printf("    Source BAM: %s\n", mapping.SourceBAM)
+			if bamExists {
+				ui.Green("      - Size: %.2f MB, Status: EXISTS\n", float64(bamSize)/(1024*1024))
+			} else {
+				ui.Red("      - Status: MISSING, Error: %v\n", bamErr)
 			}
 
-			// Debug output dir
-			logging.Debugf("output directory: %s", outputDir)
+			fmt.Printf("    Source PBI: %s\n", mapping.SourcePBI)
+			if pbiExists {
+				ui.Green("      - Size: %.2f MB, Status: EXISTS\n", float64(pbiSize)/(1024*1024))
+			} else {
+				ui.Red("      - Status: MISSING, Error: %v\n", pbiErr)
+			}
+
+			// Print destination file information
+			fmt.Printf("    Destination BAM: %s\n", mapping.DestBAM)
+			fmt.Printf("    Destination PBI: %s\n", mapping.DestPBI)
+
+			// Check if destination directory exists
+			destDir := filepath.Dir(mapping.DestBAM)
+			if _, err := os.Stat(destDir); os.IsNotExist(err) {
+				ui.Yellow("    Destination directory does not exist: %s\n", destDir)
+			}
+		}
+	}
+	extraSize := printExtraFiles(extraMappings)
+
+	// Print summary statistics
+	ui.Bold("\n=============== SUMMARY ===============\n")
+	if len(plans) > 1 {
+		fmt.Printf("Runs: %d\n", len(plans))
+	}
+	if multiCell := multiCellBioSamples(fileMappings); len(multiCell) > 0 {
+		ui.Yellow("Biosamples sequenced on multiple cells (%s): %s\n",
+			flags.GetCollisionPolicy(), strings.Join(multiCell, ", "))
+	}
+	fmt.Printf("Total files identified: %d (%d BAM + %d PBI files)\n",
+		len(hifiMappings)*2, len(hifiMappings), len(hifiMappings))
+	if len(extraMappings) > 0 {
+		fmt.Printf("Optional files: %d (%s)\n", countFiles(extraMappings), ui.FormatBytes(extraSize))
+	}
+	ui.Green("Valid files found: %d\n", validFileCount)
+	if invalidFileCount > 0 {
+		ui.Red("Missing files: %d\n", invalidFileCount)
+	} else {
+		fmt.Printf("Missing files: %d\n", invalidFileCount)
+	}
+	fmt.Printf("Total data size: %.2f GB (BAM: %.2f GB, PBI: %.2f GB)\n",
+		float64(totalBAMSize+totalPBISize)/(1024*1024*1024),
+		float64(totalBAMSize)/(1024*1024*1024),
+		float64(totalPBISize)/(1024*1024*1024))
+	ui.Bold("========================================\n")
+	return invalidFileCount, len(hifiMappings) * 2
+}
 
-			// Identify files to copy
-			logging.Debugf("identifying HiFi files across %d metadata files", len(metadataFiles))
-			fileMappings, err := fileops.IdentifyAllHiFiFiles(metadataFiles, metadataFileToBiosample, outputDir)
+// copyPlans copies the files of each plan in its own journaled session. With
+// several runs, a failed run does not stop the others and a combined summary is
+// printed; runs that cannot be copied are listed there as failed.
+func copyPlans(ctx context.Context, plans []*runPlan, outputDir string) error {
+	// Check if we're in dry-run mode
+	dryRunMode := flags.GetDryRunMode()
+	verboseMode := flags.GetDebugMode()
+
+	if dryRunMode {
+		ui.Yellow("\n[DRY RUN] Copy operations will be simulated but not executed\n")
+	} else {
+		ui.Italic("\nProceeding with file copying...\n")
+	}
+
+	var todo []*runPlan
+	var outcomes, skipped []*runOutcome
+	for _, plan := range plans {
+		if err := plan.problem(); err != nil {
+			o := &runOutcome{run: plan.run}
+			o.setResult(err)
+			skipped = append(skipped, o)
+		} else if len(plan.mappings) > 0 {
+			todo = append(todo, plan)
+			outcomes = append(outcomes, &runOutcome{run: plan.run, status: "not started"})
+		}
+	}
+	for i, o := range outcomes {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(outcomes)+len(skipped) > 1 {
+			ui.Bold("\n=============== [%d/%d] %s ===============\n", i+1, len(outcomes), o.run.Name)
+		}
+		// Create file copier and perform copy
+		copier, err := newFileCopier(outputDir, dryRunMode, verboseMode)
+		if err != nil {
+			return err
+		}
+		filter := ""
+		if todo[i].filter != nil {
+			filter = todo[i].filter.String()
+		}
+		err = runCopySession(ctx, copier, o.run.Name, filter, outputDir, todo[i].mappings)
+		if len(outcomes)+len(skipped) == 1 {
 			if err != nil {
-				ui.Red("Error identifying files: %v\n", err)
-			} else {
-				fmt.Printf("\nIdentified %d files to copy:\n", len(fileMappings))
-				ui.Bold("\n=============== FILE IDENTIFICATION REPORT ===============\n")
-
-				// Track totals for summary
-				var totalBAMSize, totalPBISize int64
-				var validFileCount, invalidFileCount int
-
-				for i, mapping := range fileMappings {
-					ui.Bold("\n[%d] Biosample: %s\n", i+1, mapping.BioSample)
-
-					// Check if source BAM exists and get size
-					bamInfo, bamErr := os.Stat(mapping.SourceBAM)
-					bamExists := bamErr == nil
-					bamSize := int64(0)
-					if bamExists {
-						bamSize = bamInfo.Size()
-						totalBAMSize += bamSize
-						validFileCount++
-					} else {
-						invalidFileCount++
-					}
+				return err
+			}
+			break
+		}
+		o.setResult(err)
+	}
+	if len(outcomes)+len(skipped) > 1 {
+		if err := reportRuns("COPY SUMMARY", append(outcomes, skipped...), ctx.Err()); err != nil {
+			return err
+		}
+	}
 
-					// Check if source PBI exists and get size
-					pbiInfo, pbiErr := os.Stat(mapping.SourcePBI)
-					pbiExists := pbiErr == nil
-					pbiSize := int64(0)
-					if pbiExists {
-						pbiSize = pbiInfo.Size()
-						totalPBISize += pbiSize
-						validFileCount++
-					} else {
-						invalidFileCount++
-					}
+	if dryRunMode {
+		ui.Yellow("\n[DRY RUN] Copy simulation completed successfully.\n")
+		fmt.Println("Run without --dry-run flag to perform actual copying.")
+	} else {
+		ui.Green("\nAll files copied successfully!\n")
+	}
+	return nil
+}
 
-					// Print source file information with existence status and size
-					fmt.Printf("    Source BAM: %s\n", mapping.SourceBAM)
-					if bamExists {
-						ui.Green("      - Size: %.2f MB, Status: EXISTS\n", float64(bamSize)/(1024*1024))
-					} else {
-						ui.Red("      - Status: MISSING, Error: %v\n", bamErr)
-					}
+// startedLabel describes when a run started for the run listing.
+func startedLabel(run *metadata.RunInfo, now time.Time) string {
+	if run.StartedDate.IsZero() {
+		return "Date unknown"
+	}
+	if run.DateInferred {
+		return fmt.Sprintf("Started: ~%s (%s)", ui.FormatDate(run.StartedDate), ui.RelativeAge(run.StartedDate, now))
+	}
+	return fmt.Sprintf("Started: %s (%s)", ui.FormatTime(run.StartedDate), ui.RelativeAge(run.StartedDate, now))
+}
 
-					fmt.Printf("    Source PBI: %s\n", mapping.SourcePBI)
-					if pbiExists {
-						ui.Green("      - Size: %.2f MB, Status: EXISTS\n", float64(pbiSize)/(1024*1024))
-					} else {
-						ui.Red("      - Status: MISSING, Error: %v\n", pbiErr)
-					}
+// formatReadStats renders the QC statistics on one line. Statistics that were
+// not reported are shown as n/a.
+func formatReadStats(s metadata.ReadStats) string {
+	stat := func(label string, value int64, format string) string {
+		if value <= 0 {
+			return label + " n/a"
+		}
+		return fmt.Sprintf(format, label, value)
+	}
+	yield := "yield n/a"
+	if s.HiFiYield > 0 {
+		yield = fmt.Sprintf("yield %.2f Gb", float64(s.HiFiYield)/1e9)
+	}
+	reads := "reads n/a"
+	if s.ReadCount > 0 {
+		reads = fmt.Sprintf("%d reads", s.ReadCount)
+	}
+	quality := "QV n/a"
+	if s.MeanQuality > 0 {
+		quality = fmt.Sprintf("Q%.1f", s.MeanQuality)
+	}
+	return strings.Join([]string{yield, reads, stat("mean", s.MeanReadLength, "%s %d bp"),
+		stat("median", s.MedianReadLength, "%s %d bp"), stat("N50", s.N50, "%s %d bp"), quality}, ", ")
+}
 
-					// Print destination file information
-					fmt.Printf("    Destination BAM: %s\n", mapping.DestBAM)
-					fmt.Printf("    Destination PBI: %s\n", mapping.DestPBI)
+// identifyOptions builds the file identification options from the current flags.
+func identifyOptions(outputDir string) (fileops.IdentifyOptions, error) {
+	policy, err := fileops.ParseCollisionPolicy(flags.GetCollisionPolicy())
+	if err != nil {
+		return fileops.IdentifyOptions{}, err
+	}
+	layout, err := fileops.NewLayout(flags.GetDirTemplate(), flags.GetFileTemplate())
+	if err != nil {
+		return fileops.IdentifyOptions{}, err
+	}
+	extra, err := fileops.ParseFileClasses(flags.GetInclude())
+	if err != nil {
+		return fileops.IdentifyOptions{}, err
+	}
+	return fileops.IdentifyOptions{OutputDir: outputDir, Layout: layout, Collision: policy, Extra: extra}, nil
+}
 
-					// Check if destination directory exists
-					destDir := filepath.Dir(mapping.DestBAM)
-					if _, err := os.Stat(destDir); os.IsNotExist(err) {
-						ui.Yellow("    Destination directory does not exist: %s\n", destDir)
-					}
-				}
+// splitByClass separates the HiFi mappings from those of the optional file classes.
+func splitByClass(mappings []*fileops.FileMapping) (hifi, extras []*fileops.FileMapping) {
+	for _, m := range mappings {
+		if m.IsHiFi() {
+			hifi = append(hifi, m)
+		} else {
+			extras = append(extras, m)
+		}
+	}
+	return hifi, extras
+}
 
-				// Print summary statistics
-				ui.Bold("\n=============== SUMMARY ===============\n")
-				fmt.Printf("Total files identified: %d (%d BAM + %d PBI files)\n",
-					len(fileMappings)*2, len(fileMappings), len(fileMappings))
-				ui.Green("Valid files found: %d\n", validFileCount)
-				if invalidFileCount > 0 {
-					ui.Red("Missing files: %d\n", invalidFileCount)
-				} else {
-					fmt.Printf("Missing files: %d\n", invalidFileCount)
-				}
-				fmt.Printf("Total data size: %.2f GB (BAM: %.2f GB, PBI: %.2f GB)\n",
-					float64(totalBAMSize+totalPBISize)/(1024*1024*1024),
-					float64(totalBAMSize)/(1024*1024*1024),
-					float64(totalPBISize)/(1024*1024*1024))
-				ui.Bold("========================================\n")
-
-				// If files are identified and there are no missing files, proceed with copying
-				if len(fileMappings) > 0 && invalidFileCount == 0 {
-					// Check if we're in dry-run mode
-					dryRunMode := flags.GetDryRunMode()
-					verboseMode := flags.GetDebugMode()
-
-					if dryRunMode {
-						ui.Yellow("\n[DRY RUN] Copy operations will be simulated but not executed\n")
-					} else {
-						ui.Italic("\nProceeding with file copying...\n")
-					}
+// countFiles returns the number of files of mappings.
+func countFiles(mappings []*fileops.FileMapping) int {
+	n := 0
+	for _, m := range mappings {
+		n += len(m.Files())
+	}
+	return n
+}
 
-					// Create file copier and perform copy
-					copier := copyfiles.NewFileCopier(dryRunMode, verboseMode)
-					err := copier.CopyAllFileMappings(fileMappings)
-
-					if err != nil {
-						ui.Red("\nError during file copying: %v\n", err)
-					} else if dryRunMode {
-						ui.Yellow("\n[DRY RUN] Copy simulation completed successfully.\n")
-						fmt.Println("Run without --dry-run flag to perform actual copying.")
-					} else {
-						ui.Green("\nAll files copied successfully!\n")
-					}
-				} else if invalidFileCount > 0 {
-					ui.Red("\nCannot proceed with copying due to missing source files.\n")
-					fmt.Println("Please check the file identification report above.")
+// printExtraFiles lists the optional files grouped by class and returns their total size.
+func printExtraFiles(extras []*fileops.FileMapping) int64 {
+	byClass := make(map[fileops.FileClass][]*fileops.FileMapping)
+	for _, m := range extras {
+		byClass[m.Class] = append(byClass[m.Class], m)
+	}
+	var total int64
+	for _, class := range fileops.ExtraClasses {
+		mappings := byClass[class]
+		if len(mappings) == 0 {
+			continue
+		}
+		var size int64
+		for _, m := range mappings {
+			for _, f := range m.Files() {
+				if info, err := os.Stat(f.Source); err == nil {
+					size += info.Size()
 				}
 			}
-		} else {
-			fmt.Printf("\nUse --output flag to identify files for copying\n")
 		}
+		total += size
+		ui.Bold("\n[%s] %d files, %s\n", class, countFiles(mappings), ui.FormatBytes(size))
+		for _, m := range mappings {
+			for _, f := range m.Files() {
+				fmt.Printf("    %s -> %s\n", f.Source, f.Dest)
+			}
+		}
+	}
+	return total
+}
 
-		if flags.GetDryRunMode() {
-			fmt.Printf("\nFile identification and dry-run complete.\n")
-		} else {
-			ui.Green("\nFile processing complete.\n")
+// multiCellBioSamples returns the sorted names of biosamples whose files come from several cells.
+func multiCellBioSamples(mappings []*fileops.FileMapping) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range mappings {
+		if m.MultiCell && !seen[m.BioSample] {
+			seen[m.BioSample] = true
+			names = append(names, m.BioSample)
 		}
+	}
+	sort.Strings(names)
+	return names
+}
 
-		return nil
-	},
+// valueOrUnknown returns s, or "unknown" when s is empty.
+func valueOrUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// selectionPrompt reads selections of numbered items: numbers and ranges
+// (1,3-5), 'a' for all items shown, or any other text to show only the
+// matching items. An empty line shows all items again.
+type selectionPrompt struct {
+	prompt string
+	count  int
+	match  func(i int, text string) bool // Reports whether item i matches the filter text
+	print  func(indexes []int)           // Lists the items at indexes
+	shown  []int                         // Items shown after the last filter; nil means all
 }
 
-// promptForSelection prompts the user to select an option by number.
-// It returns the selected index (0-based), -1 for an error, or -2 to quit.
-func promptForSelection(prompt string, max int) int {
-	reader := bufio.NewReader(os.Stdin)
+// read prompts until the user enters a selection and returns the 0-based
+// indexes; all reports that 'a' was entered. It returns no indexes when the user quits.
+func (p *selectionPrompt) read(ctx context.Context) (indexes []int, all bool, err error) {
 	for {
-		fmt.Printf("%s (1-%d, or 'q' to quit): ", prompt, max)
-		input, err := reader.ReadString('\n')
+		fmt.Printf("%s (e.g. 1,3-5; 'a' for all; text to filter; 'q' to quit): ", p.prompt)
+		input, err := readLine(ctx, stdin)
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, false, ctxErr
+			}
 			fmt.Println("Error reading input:", err)
-			return -1
+			return nil, false, fmt.Errorf("invalid selection")
 		}
 
 		// Trim whitespace and check for quit command
 		input = strings.TrimSpace(input)
-		if strings.ToLower(input) == "q" {
-			return -2
+		switch strings.ToLower(input) {
+		case "q":
+			return nil, false, nil
+		case "a", "all":
+			if p.shown != nil {
+				return p.shown, true, nil
+			}
+			for i := 0; i < p.count; i++ {
+				indexes = append(indexes, i)
+			}
+			return indexes, true, nil
+		}
+		if isSelection(input) {
+			if indexes, err = parseSelection(input, p.count); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			return indexes, false, nil
 		}
 
-		// Convert to int
-		selected, err := strconv.Atoi(input)
-		if err != nil || selected < 1 || selected > max {
-			fmt.Printf("Please enter a number between 1 and %d\n", max)
+		// Filter the list; an empty line shows all items again
+		var matches []int
+		for i := 0; i < p.count; i++ {
+			if input == "" || p.match(i, input) {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) == 0 {
+			ui.Yellow("No match for %q.\n", input)
 			continue
 		}
+		p.shown = matches
+		if input == "" {
+			p.shown = nil
+		}
+		p.print(matches)
+	}
+}
+
+// promptForRuns lists runs and prompts the user to select one or more of them.
+// 'a' selects the complete runs shown; pending runs in a selection are skipped
+// with a warning. It returns no runs when the user quits.
+func promptForRuns(ctx context.Context, runs []*metadata.RunInfo) ([]*metadata.RunInfo, error) {
+	p := &selectionPrompt{
+		prompt: "Select runs",
+		count:  len(runs),
+		match:  func(i int, text string) bool { return runMatches(runs[i], text) },
+		print:  func(indexes []int) { printRunList(runs, indexes) },
+	}
+	p.print(allIndexes(len(runs)))
+	for {
+		indexes, all, err := p.read(ctx)
+		if err != nil || indexes == nil {
+			return nil, err
+		}
+
+		var selected []*metadata.RunInfo
+		for _, i := range indexes {
+			if runs[i].Status == metadata.RunPending {
+				if !all {
+					ui.Yellow("Skipping run %s: it is pending.\n", runs[i].Name)
+				}
+				continue
+			}
+			selected = append(selected, runs[i])
+		}
+		if len(selected) == 0 {
+			ui.Yellow("No complete run selected. Please choose another run.\n")
+			continue
+		}
+		for _, run := range selected {
+			fmt.Printf("Selected run: %s\n", run.Name)
+		}
+		return selected, nil
+	}
+}
+
+// pickBioSamples prompts for the biosamples to copy of each run and returns
+// them by run name; runs of which every biosample was chosen are left out. It
+// returns nil when the user quits.
+func pickBioSamples(ctx context.Context, runs []*metadata.RunInfo) (map[string][]string, error) {
+	samples := make(map[string][]string)
+	for _, run := range runs {
+		names := make([]string, 0, run.BioSampleCount())
+		for name := range run.BioSampleNames {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		p := &selectionPrompt{
+			prompt: "Select biosamples",
+			count:  len(names),
+			match: func(i int, text string) bool {
+				return strings.Contains(strings.ToLower(names[i]), strings.ToLower(text))
+			},
+			print: func(indexes []int) {
+				for _, i := range indexes {
+					fmt.Printf("%d. %s\n", i+1, names[i])
+				}
+			},
+		}
+		ui.Bold("\nBiosamples of run %s:\n", run.Name)
+		p.print(allIndexes(len(names)))
+		indexes, _, err := p.read(ctx)
+		if err != nil || indexes == nil {
+			return nil, err
+		}
+		if len(indexes) == len(names) {
+			continue
+		}
+		for _, i := range indexes {
+			samples[run.Name] = append(samples[run.Name], names[i])
+		}
+		fmt.Printf("Selected %d of %d biosamples: %s\n", len(indexes), len(names), strings.Join(samples[run.Name], ", "))
+	}
+	return samples, nil
+}
+
+// sampleFilter builds the biosample and cell filter from the process flags.
+func sampleFilter() (*fileops.Filter, error) {
+	return fileops.NewFilter(processSamples, processExcludeSample, processCells, processWells)
+}
+
+// allIndexes returns 0, 1, ..., n-1.
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
 
-		return selected - 1 // Convert to zero-based index
+// printRunList prints the runs at the given indexes, numbered by their position in runs.
+func printRunList(runs []*metadata.RunInfo, indexes []int) {
+	if len(indexes) == len(runs) {
+		ui.Bold("Available runs (sorted by started date, newest first):\n")
+	} else {
+		ui.Bold("Matching runs (%d of %d; enter an empty line to show all):\n", len(indexes), len(runs))
 	}
+	now := time.Now()
+	for _, i := range indexes {
+		run := runs[i]
+		dateStr := startedLabel(run, now)
+		if run.Status == metadata.RunPending {
+			fmt.Printf("%d. %s - %s (%d biosamples)", i+1, run.Name, dateStr, run.BioSampleCount())
+			ui.Yellow(" (pending)\n")
+		} else {
+			ui.Green("%d. %s - %s (%d biosamples)\n",
+				i+1, run.Name, dateStr, run.BioSampleCount())
+		}
+	}
+}
+
+// runMatches reports whether the name or a biosample name of run contains text, ignoring case.
+func runMatches(run *metadata.RunInfo, text string) bool {
+	text = strings.ToLower(text)
+	if strings.Contains(strings.ToLower(run.Name), text) {
+		return true
+	}
+	for biosample := range run.BioSampleNames {
+		if strings.Contains(strings.ToLower(biosample), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelection reports whether input looks like a list of numbers and ranges
+// rather than text to filter by.
+func isSelection(input string) bool {
+	if input == "" {
+		return false
+	}
+	for _, r := range input {
+		if (r < '0' || r > '9') && !strings.ContainsRune(", -", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSelection parses a comma-separated list of 1-based numbers and ranges
+// such as "1,3-5" and returns the 0-based indexes in order, without duplicates.
+func parseSelection(input string, max int) ([]int, error) {
+	var indexes []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(last))
+		}
+		if err != nil || from < 1 || to > max || from > to {
+			return nil, fmt.Errorf("invalid selection %q: enter numbers or ranges between 1 and %d", part, max)
+		}
+		for n := from; n <= to; n++ {
+			if !seen[n] {
+				seen[n] = true
+				indexes = append(indexes, n-1)
+			}
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("please enter numbers or ranges between 1 and %d", max)
+	}
+	return indexes, nil
 }
 
-func init() { rootCmd.AddCommand(processCmd) }
+var (
+	processNoTUI         bool
+	processSamples       []string
+	processExcludeSample []string
+	processCells         []string
+	processWells         []string
+	processPickSamples   bool
+)
+
+// stdin is shared by all prompts so that input read ahead is not lost between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+func init() {
+	processCmd.Flags().BoolVar(&processNoTUI, "no-tui", false, "select runs at a numbered prompt instead of the full-screen browser")
+	processCmd.Flags().StringArrayVar(&processSamples, "sample", nil, "only copy biosamples matching this glob, or regular expression with a re: prefix (repeatable)")
+	processCmd.Flags().StringArrayVar(&processExcludeSample, "exclude-sample", nil, "do not copy biosamples matching this glob or re: expression (repeatable)")
+	processCmd.Flags().StringArrayVar(&processCells, "cell", nil, "only copy cells whose movie name or cell barcode matches (repeatable)")
+	processCmd.Flags().StringArrayVar(&processWells, "well", nil, "only copy cells in a matching well, e.g. 1_A01 or A01 (repeatable)")
+	processCmd.Flags().BoolVar(&processPickSamples, "pick-samples", false, "choose the biosamples of each run at a prompt")
+	rootCmd.AddCommand(processCmd)
+}
+
+// readLine reads one line from reader, returning early with ctx.Err() if ctx is cancelled.
+func readLine(ctx context.Context, reader *bufio.Reader) (string, error) {
+	type line struct {
+		text string
+		err  error
+	}
+	ch := make(chan line, 1)
+	go func() {
+		text, err := reader.ReadString('\n')
+		ch <- line{text, err}
+	}()
+	select {
+	case l := <-ch:
+		return l.text, l.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
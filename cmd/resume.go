@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/journal"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+)
+
+// resumeCmd continues an interrupted copy session from its journal
+var resumeCmd = &cobra.Command{
+	Use:   "resume [journal]",
+	Short: "Continue an interrupted copy session from its journal",
+	Long: `Continue a copy session from its journal file. Without an argument the journal of
+the most recently started session in the state directory (--state-dir, default <output>/.revio-copy) is used.
+Files the journal records as completed are skipped if they are unchanged on disk; all other
+files are verified against their source and copied if missing or different.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var path string
+		if len(args) == 1 {
+			path = args[0]
+		} else {
+			if flags.GetOutputDir() == "" && flags.GetStateDir() == "" {
+				return fmt.Errorf("specify a journal file, --output or --state-dir")
+			}
+			latest, err := journal.Latest(stateDir(flags.GetOutputDir()))
+			if err != nil {
+				return err
+			}
+			path = latest
+		}
+
+		replay, err := journal.Read(path)
+		if err != nil {
+			return err
+		}
+
+		ui.Bold("Resuming session %s\n", replay.Session)
+		fmt.Printf("Journal: %s\n", replay.Path)
+		fmt.Printf("Run: %s\n", replay.Run)
+		fmt.Printf("Output directory: %s\n", replay.OutputDir)
+		fmt.Printf("Planned files: %d, completed: %d, failed: %d, remaining: %d\n",
+			countFiles(replay.Mappings), len(replay.Completed), len(replay.Failed), replay.Pending())
+		if replay.Finished && replay.Pending() == 0 {
+			ui.Green("\nSession already finished; all files were delivered.\n")
+			return nil
+		}
+
+		if flags.GetDryRunMode() {
+			ui.Yellow("\n[DRY RUN] Copy operations will be simulated but not executed\n")
+		}
+
+		copier, err := newFileCopier(replay.OutputDir, flags.GetDryRunMode(), flags.GetDebugMode())
+		if err != nil {
+			return err
+		}
+		if !flags.GetDryRunMode() {
+			if err := copier.Backend.Available(); err != nil {
+				return err
+			}
+		}
+		if err := preflight(replay.Mappings, copier.DryRun, true); err != nil {
+			return err
+		}
+		if copier.DryRun {
+			return reportCopyResult(copyMappings(cmd.Context(), copier, replay.Mappings))
+		}
+
+		copier.Resume = true
+		replay.SeedManifest(copier.Manifest)
+
+		j, err := journal.Append(replay.Path)
+		if err != nil {
+			return err
+		}
+		if err := j.Resumed(); err != nil {
+			j.Close(0, 0, 0)
+			return fmt.Errorf("writing journal: %w", err)
+		}
+
+		if err := copyWithJournal(cmd.Context(), copier, j, replay.Run, replay.Filter, replay.OutputDir, replay.Mappings); err != nil {
+			return err
+		}
+		ui.Green("\nResume complete.\n")
+		return nil
+	},
+}
+
+func init() { rootCmd.AddCommand(resumeCmd) }
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
 	"github.com/schnurbe/revio-copy/pkg/flags"
 	"github.com/schnurbe/revio-copy/pkg/logging"
 	"github.com/spf13/cobra"
@@ -17,6 +21,23 @@ var (
 	runName   string
 	debugMode bool
 	dryRun    bool
+
+	collisionPolicy string
+	dirTemplate     string
+	fileTemplate    string
+	configFile      string
+	copyBackend     string
+	checksumList    string
+	verifyCopies    bool
+	copyJobs        int
+	bwLimit         string
+	resumeMode      bool
+	interruptMode   string
+	forceCopy       bool
+	spaceMargin     string
+	sumFiles        string
+	includeList     string
+	stateDirFlag    string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -25,36 +46,71 @@ var rootCmd = &cobra.Command{
 	Short: "Process PacBio Revio sequencing data",
 	Long: `revio-copy lists runs and (optionally) copies HiFi read BAM/PBI files for PacBio Revio sequencing data.
 It works in two phases: 1) discover + display metadata; 2) when an output directory is supplied, identify/copy files.`,
+	// Execute prints the error and exits with a code describing the failure
+	SilenceErrors: true,
 	// Ensure flags are synchronized and debug logging toggled.
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments are valid at this point; later errors are not usage errors
+		cmd.SilenceUsage = true
+		if err := readConfig(); err != nil {
+			return err
+		}
 		updateFlags()
 		if flags.GetDebugMode() {
 			logging.EnableDebug()
 		} else {
 			logging.DisableDebug()
 		}
+		return nil
 	},
 }
 
-// checkRcloneAvailability checks if rclone is available in the system path
-func checkRcloneAvailability() error {
-	cmd := exec.Command("rclone", "version")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("rclone not found or not executable: %w", err)
+// readConfig loads the optional config file. Keys match the long flag names.
+func readConfig() error {
+	path := configFile
+	if path == "" {
+		path = defaultConfigFile()
+		if path == "" {
+			return nil
+		}
 	}
-	versionLine := strings.Split(string(output), "\n")[0]
-	logging.Debugf("rclone available: %s", versionLine)
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("reading config %s: %w", path, err)
+	}
+	logging.Debugf("using config file %s", viper.ConfigFileUsed())
 	return nil
 }
 
+// defaultConfigFile returns the first existing default config file, or "" when there is none.
+func defaultConfigFile() string {
+	candidates := []string{"revio-copy.yaml"}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".config", "revio-copy", "config.yaml"))
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c
+		}
+	}
+	return ""
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // Execute runs the CLI. Only minimal setup is done here; heavy lifting in subcommands.
 func Execute() {
 	viper.AutomaticEnv()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	ctx, stop := handleInterrupts(context.Background())
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		var failure *copyFailure
+		if !errors.As(err, &failure) && errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "Interrupted.")
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -64,6 +120,22 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&runName, "run", "", "specific run name to process")
 	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "enable debug output")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "identify files without copying")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default: $HOME/.config/revio-copy/config.yaml or ./revio-copy.yaml)")
+	rootCmd.PersistentFlags().StringVar(&dirTemplate, "dir-template", fileops.DefaultDirTemplate, "destination directory template (Go text/template; fields: RunName, RunDate, BioSample, Barcode, Well, Movie, Instrument)")
+	rootCmd.PersistentFlags().StringVar(&fileTemplate, "file-template", fileops.DefaultFileTemplate, "destination BAM file name template (the PBI gets a .pbi extension)")
+	rootCmd.PersistentFlags().StringVar(&copyBackend, "copier", "auto", "copy backend: auto (native when --sums writes checksum files, else rclone if installed), native or rclone")
+	rootCmd.PersistentFlags().StringVar(&checksumList, "checksum", "md5", "checksums computed while copying with the native backend: md5, sha256, xxhash (comma-separated)")
+	rootCmd.PersistentFlags().BoolVar(&verifyCopies, "verify-after-copy", true, "re-read each destination file after copying and compare checksums (native backend)")
+	rootCmd.PersistentFlags().IntVarP(&copyJobs, "jobs", "j", 1, "number of biosamples copied in parallel")
+	rootCmd.PersistentFlags().StringVar(&bwLimit, "bwlimit", "", "total bandwidth limit shared by all jobs in bytes/s, e.g. 500M or 1G; rclone splits it evenly between its parallel copies (empty = unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&resumeMode, "skip-existing", false, "skip destination files that already hold a verified copy of their source")
+	rootCmd.PersistentFlags().StringVar(&interruptMode, "on-interrupt", string(copyfiles.InterruptFinish), "on SIGINT/SIGTERM, let copies in progress finish or abort them (finish, abort)")
+	rootCmd.PersistentFlags().StringVar(&sumFiles, "sums", string(copyfiles.HashMD5), "checksum files written per sample and per delivery (md5 → MD5SUMS, sha256 → SHA256SUMS; comma-separated, or none)")
+	rootCmd.PersistentFlags().BoolVar(&forceCopy, "force", false, "copy even if pre-flight free-space or permission checks fail")
+	rootCmd.PersistentFlags().StringVar(&spaceMargin, "space-margin", "5%", "free space to leave on destination filesystems, as a size (e.g. 100G) or a percentage of the data copied")
+	rootCmd.PersistentFlags().StringVar(&stateDirFlag, "state-dir", "", "directory for the copy manifest and session journals (default <output>/.revio-copy)")
+	rootCmd.PersistentFlags().StringVar(&includeList, "include", "", "optional files delivered with the HiFi reads: fail_reads, unassigned, statistics, consensusreadset, metadata (comma-separated, or all)")
+	rootCmd.PersistentFlags().StringVar(&collisionPolicy, "on-collision", "suffix", "what to do when a biosample appears in several cells: suffix, subfolder or fail")
 
 	// Set prefix for environment variables (REVIO_RUN instead of just RUN)
 	viper.SetEnvPrefix("REVIO")
@@ -76,6 +148,21 @@ func init() {
 	viper.BindPFlag("run", rootCmd.PersistentFlags().Lookup("run"))
 	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	viper.BindPFlag("on-collision", rootCmd.PersistentFlags().Lookup("on-collision"))
+	viper.BindPFlag("copier", rootCmd.PersistentFlags().Lookup("copier"))
+	viper.BindPFlag("checksum", rootCmd.PersistentFlags().Lookup("checksum"))
+	viper.BindPFlag("verify-after-copy", rootCmd.PersistentFlags().Lookup("verify-after-copy"))
+	viper.BindPFlag("jobs", rootCmd.PersistentFlags().Lookup("jobs"))
+	viper.BindPFlag("bwlimit", rootCmd.PersistentFlags().Lookup("bwlimit"))
+	viper.BindPFlag("skip-existing", rootCmd.PersistentFlags().Lookup("skip-existing"))
+	viper.BindPFlag("on-interrupt", rootCmd.PersistentFlags().Lookup("on-interrupt"))
+	viper.BindPFlag("sums", rootCmd.PersistentFlags().Lookup("sums"))
+	viper.BindPFlag("force", rootCmd.PersistentFlags().Lookup("force"))
+	viper.BindPFlag("space-margin", rootCmd.PersistentFlags().Lookup("space-margin"))
+	viper.BindPFlag("state-dir", rootCmd.PersistentFlags().Lookup("state-dir"))
+	viper.BindPFlag("include", rootCmd.PersistentFlags().Lookup("include"))
+	viper.BindPFlag("dir-template", rootCmd.PersistentFlags().Lookup("dir-template"))
+	viper.BindPFlag("file-template", rootCmd.PersistentFlags().Lookup("file-template"))
 }
 
 // updateFlags updates the flags package with the current flag values
@@ -84,5 +171,30 @@ func updateFlags() {
 	runName = viper.GetString("run")
 	debugMode = viper.GetBool("debug")
 	dryRun = viper.GetBool("dry-run")
+	collisionPolicy = viper.GetString("on-collision")
+	dirTemplate = viper.GetString("dir-template")
+	fileTemplate = viper.GetString("file-template")
 	flags.SetFlags(outputDir, runName, debugMode, dryRun)
+	flags.SetCollisionPolicy(collisionPolicy)
+	flags.SetLayoutTemplates(dirTemplate, fileTemplate)
+	copyBackend = viper.GetString("copier")
+	checksumList = viper.GetString("checksum")
+	verifyCopies = viper.GetBool("verify-after-copy")
+	flags.SetCopyOptions(copyBackend, checksumList, verifyCopies)
+	copyJobs = viper.GetInt("jobs")
+	bwLimit = viper.GetString("bwlimit")
+	flags.SetConcurrency(copyJobs, bwLimit)
+	resumeMode = viper.GetBool("skip-existing")
+	flags.SetResumeMode(resumeMode)
+	interruptMode = viper.GetString("on-interrupt")
+	flags.SetInterruptMode(interruptMode)
+	forceCopy = viper.GetBool("force")
+	spaceMargin = viper.GetString("space-margin")
+	flags.SetPreflight(forceCopy, spaceMargin)
+	sumFiles = viper.GetString("sums")
+	flags.SetSumFiles(sumFiles)
+	stateDirFlag = viper.GetString("state-dir")
+	flags.SetStateDir(stateDirFlag)
+	includeList = viper.GetString("include")
+	flags.SetInclude(includeList)
 }
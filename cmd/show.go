@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var showFormat string
+
+// runDetails is everything known about one run, as printed by show.
+type runDetails struct {
+	runListing  `yaml:",inline"`
+	CellDetails []*cellDetails `json:"cell_details" yaml:"cell_details"`
+}
+
+// cellDetails describes one cell of a run.
+type cellDetails struct {
+	Status       string              `json:"status" yaml:"status"`
+	Well         string              `json:"well,omitempty" yaml:"well,omitempty"`
+	Movie        string              `json:"movie,omitempty" yaml:"movie,omitempty"`
+	CellBarcode  string              `json:"cell_barcode,omitempty" yaml:"cell_barcode,omitempty"`
+	Instrument   string              `json:"instrument,omitempty" yaml:"instrument,omitempty"`
+	MetadataPath string              `json:"metadata_path" yaml:"metadata_path"`
+	BioSamples   []*bioSampleDetails `json:"biosamples" yaml:"biosamples"`
+	Warnings     []string            `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// bioSampleDetails describes a biosample of a cell and its resolved HiFi files.
+type bioSampleDetails struct {
+	Name    string       `json:"name" yaml:"name"`
+	Barcode string       `json:"barcode,omitempty" yaml:"barcode,omitempty"`
+	BAM     *fileDetails `json:"bam,omitempty" yaml:"bam,omitempty"`
+	PBI     *fileDetails `json:"pbi,omitempty" yaml:"pbi,omitempty"`
+}
+
+// fileDetails is a resolved source file.
+type fileDetails struct {
+	Path   string `json:"path" yaml:"path"`
+	Size   int64  `json:"size_bytes" yaml:"size_bytes"`
+	Exists bool   `json:"exists" yaml:"exists"`
+}
+
+// showCmd prints the details of one run
+var showCmd = &cobra.Command{
+	Use:   "show [directory]",
+	Short: "Show the details of a run",
+	Long: `Show everything known about the run selected with --run: each cell with its metadata path,
+well, movie and state (complete or pending), the biosamples with their barcodes, the HiFi BAM/PBI
+files they resolve to with sizes, and any parse or identification warnings. No output directory is
+needed. --format json or yaml print the same information in machine-readable form.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if flags.GetRunName() == "" {
+			return fmt.Errorf("--run is required")
+		}
+		if _, err := identifyOptions(""); err != nil {
+			return err
+		}
+		return checkFormat(showFormat, formatTable, formatJSON, formatYAML)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rootDir := args[0]
+		runs, err := metadata.GetAllRuns(cmd.Context(), rootDir)
+		if err != nil {
+			return err
+		}
+		var run *metadata.RunInfo
+		for _, r := range runs {
+			if r.Name == flags.GetRunName() {
+				run = r
+				break
+			}
+		}
+		if run == nil {
+			return fmt.Errorf("run '%s' not found", flags.GetRunName())
+		}
+
+		details, err := newRunDetails(cmd.Context(), rootDir, run)
+		if err != nil {
+			return err
+		}
+		details.printWarnings()
+		return writeRunDetails(os.Stdout, showFormat, details)
+	},
+}
+
+// newRunDetails collects the cells of run, including cells whose transfer is
+// still pending, and resolves the HiFi files of every biosample.
+func newRunDetails(ctx context.Context, rootDir string, run *metadata.RunInfo) (*runDetails, error) {
+	listing, err := newRunListing(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	details := &runDetails{runListing: listing}
+
+	opts, err := identifyOptions("")
+	if err != nil {
+		return nil, err
+	}
+	for _, cell := range run.Cells {
+		details.CellDetails = append(details.CellDetails, newCellDetails(cell, opts))
+	}
+	pending, err := pendingCells(rootDir, run)
+	if err != nil {
+		return nil, err
+	}
+	for _, cell := range pending {
+		details.CellDetails = append(details.CellDetails, newCellDetails(cell, opts))
+	}
+	details.Cells = len(details.CellDetails)
+	return details, ctx.Err()
+}
+
+// pendingCells returns the cells of run whose transfer has not finished. The run
+// directories are those of its complete cells, or <rootDir>/<run> for pending runs.
+func pendingCells(rootDir string, run *metadata.RunInfo) ([]*metadata.MetadataInfo, error) {
+	var runDirs []string
+	seen := make(map[string]bool)
+	for _, cell := range run.Cells {
+		if dir := filepath.Dir(cell.CellDir()); !seen[dir] {
+			seen[dir] = true
+			runDirs = append(runDirs, dir)
+		}
+	}
+	if len(runDirs) == 0 {
+		runDirs = append(runDirs, filepath.Join(rootDir, run.Name))
+	}
+	var pending []*metadata.MetadataInfo
+	for _, dir := range runDirs {
+		cells, err := metadata.FindPendingCells(dir)
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s for pending cells: %w", dir, err)
+		}
+		pending = append(pending, cells...)
+	}
+	return pending, nil
+}
+
+// newCellDetails describes cell; the files of complete cells are resolved with opts.
+func newCellDetails(cell *metadata.MetadataInfo, opts fileops.IdentifyOptions) *cellDetails {
+	c := &cellDetails{
+		Status:       string(cell.Status),
+		Well:         cell.Cell.Position(),
+		Movie:        cell.Cell.MovieName,
+		CellBarcode:  cell.Cell.CellBarcode,
+		Instrument:   cell.Cell.Instrument(),
+		MetadataPath: cell.FilePath,
+		BioSamples:   []*bioSampleDetails{},
+		Warnings:     cell.Warnings,
+	}
+	if cell.Status == metadata.RunPending {
+		return c
+	}
+
+	mappings, err := fileops.IdentifyHiFiFiles(cell, opts)
+	if err != nil {
+		c.Warnings = append(c.Warnings, strings.Split(err.Error(), "\n")...)
+	}
+	for _, bs := range cell.BioSamples {
+		b := &bioSampleDetails{Name: bs.Name, Barcode: bs.Barcode}
+		for _, m := range mappings {
+			if m.BioSample == bs.Name && m.Barcode == bs.Barcode {
+				b.BAM, b.PBI = statFile(m.SourceBAM), statFile(m.SourcePBI)
+				break
+			}
+		}
+		c.BioSamples = append(c.BioSamples, b)
+	}
+	return c
+}
+
+// statFile describes the file at path.
+func statFile(path string) *fileDetails {
+	f := &fileDetails{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		f.Size, f.Exists = info.Size(), true
+	}
+	return f
+}
+
+// writeRunDetails writes details in the given format.
+func writeRunDetails(w io.Writer, format string, details *runDetails) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(details)
+	case formatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(details); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	ui.Bold("Run: %s\n", details.Name)
+	if details.Status == string(metadata.RunPending) {
+		ui.Yellow("Status: %s\n", details.Status)
+	} else {
+		ui.Green("Status: %s\n", details.Status)
+	}
+	if details.Created != nil {
+		fmt.Fprintf(w, "Created: %s\n", ui.FormatTime(*details.Created))
+	}
+	if details.Started != nil {
+		if details.DateInferred {
+			fmt.Fprintf(w, "Started: ~%s (from run name)\n", ui.FormatDate(*details.Started))
+		} else {
+			fmt.Fprintf(w, "Started: %s\n", ui.FormatTime(*details.Started))
+		}
+	}
+	fmt.Fprintf(w, "Cells: %d complete, %d pending\n", details.Cells-countPending(details), countPending(details))
+	fmt.Fprintf(w, "Biosamples: %d\n", details.BioSamples)
+	if details.SizeBytes > 0 {
+		fmt.Fprintf(w, "Size: %s\n", ui.FormatBytes(details.SizeBytes))
+	}
+
+	for i, c := range details.CellDetails {
+		ui.Bold("\n[%d] Cell %s", i+1, valueOrUnknown(c.Well))
+		if c.Movie != "" {
+			ui.Bold(" - %s", c.Movie)
+		}
+		if c.Status == string(metadata.RunPending) {
+			ui.Yellow(" (pending)\n")
+		} else {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "    Metadata: %s\n", c.MetadataPath)
+		if c.Instrument != "" {
+			fmt.Fprintf(w, "    Instrument: %s\n", c.Instrument)
+		}
+		if c.CellBarcode != "" {
+			fmt.Fprintf(w, "    Cell barcode: %s\n", c.CellBarcode)
+		}
+		for _, b := range c.BioSamples {
+			fmt.Fprintf(w, "    Biosample: %s", b.Name)
+			if b.Barcode != "" {
+				fmt.Fprintf(w, " (%s)", b.Barcode)
+			}
+			fmt.Fprintln(w)
+			if b.BAM == nil {
+				ui.Red("      Not resolved to a HiFi BAM file\n")
+				continue
+			}
+			for _, f := range []struct {
+				kind string
+				file *fileDetails
+			}{{"BAM", b.BAM}, {"PBI", b.PBI}} {
+				if f.file.Exists {
+					fmt.Fprintf(w, "      %s: %s (%s)\n", f.kind, f.file.Path, ui.FormatBytes(f.file.Size))
+				} else {
+					ui.Red("      %s: %s (MISSING)\n", f.kind, f.file.Path)
+				}
+			}
+		}
+		for _, warning := range c.Warnings {
+			ui.Yellow("    Warning: %s\n", warning)
+		}
+	}
+	return nil
+}
+
+// countPending returns the number of pending cells of details.
+func countPending(details *runDetails) int {
+	n := 0
+	for _, c := range details.CellDetails {
+		if c.Status == string(metadata.RunPending) {
+			n++
+		}
+	}
+	return n
+}
+
+func init() {
+	showCmd.Flags().StringVar(&showFormat, "format", formatTable, "output format: table, json or yaml")
+	rootCmd.AddCommand(showCmd)
+}
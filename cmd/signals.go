@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+)
+
+// interruptHandler turns SIGINT/SIGTERM into context cancellation. The first
+// signal cancels the command context, which stops scanning and scheduling; a
+// second one aborts in-flight copies; a third exits immediately.
+type interruptHandler struct {
+	mu     sync.Mutex
+	count  int
+	cancel context.CancelFunc
+	abort  func() // Aborts in-flight copies; nil when nothing is being copied
+}
+
+// interrupts is the handler installed by Execute.
+var interrupts *interruptHandler
+
+// handleInterrupts returns a context cancelled on the first SIGINT or SIGTERM and a
+// function that uninstalls the handler.
+func handleInterrupts(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	h := &interruptHandler{cancel: cancel}
+	interrupts = h
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				h.handle(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel()
+	}
+}
+
+func (h *interruptHandler) handle(sig os.Signal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	switch {
+	case h.count == 1:
+		if h.abort != nil && flags.GetInterruptMode() == string(copyfiles.InterruptAbort) {
+			fmt.Fprintf(os.Stderr, "\n%v received: aborting copies in progress.\n", sig)
+		} else if h.abort != nil {
+			fmt.Fprintf(os.Stderr, "\n%v received: no new copies will be started. Interrupt again to abort copies in progress.\n", sig)
+		} else {
+			fmt.Fprintf(os.Stderr, "\n%v received, stopping.\n", sig)
+		}
+		h.cancel()
+	case h.count == 2 && h.abort != nil:
+		fmt.Fprintf(os.Stderr, "\n%v received: aborting copies in progress.\n", sig)
+		h.abort()
+	default:
+		fmt.Fprintf(os.Stderr, "\n%v received again, exiting immediately.\n", sig)
+		os.Exit(exitInterrupted)
+	}
+}
+
+// setAbort registers the function that aborts in-flight copies; nil clears it.
+func (h *interruptHandler) setAbort(abort func()) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.abort = abort
+}
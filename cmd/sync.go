@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/delivery"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+)
+
+var syncSince string
+
+// syncCmd delivers every complete run that has not been delivered yet
+var syncCmd = &cobra.Command{
+	Use:   "sync [directory]",
+	Short: "Deliver every complete run not yet delivered to the output directory",
+	Long: `Find every complete run in the directory without a successful delivery to --output (see
+<output>/.revio-copy/deliveries.json) and copy them all, oldest first (runs without a date
+last), with a combined summary. Pending runs and runs with cells still transferring are skipped.
+
+--since limits the runs to those started on or after a date (2025-09-22 or RFC 3339) or within a
+period (48h, 7d); runs without a known date are then skipped. A failed run does not stop the
+others; it is retried by the next sync.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if flags.GetOutputDir() == "" {
+			return fmt.Errorf("--output is required")
+		}
+		if _, err := parseSince(syncSince, time.Now()); err != nil {
+			return err
+		}
+		return validateCopyFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rootDir, outputDir := args[0], flags.GetOutputDir()
+		since, _ := parseSince(syncSince, time.Now())
+
+		ui.Italic("Scanning for runs in %s...\n", rootDir)
+		runs, err := metadata.GetAllRuns(cmd.Context(), rootDir)
+		if err != nil {
+			return err
+		}
+		registry, err := delivery.LoadRegistry(delivery.RegistryPath(stateDir(outputDir)))
+		if err != nil {
+			return err
+		}
+
+		var todo []*metadata.RunInfo
+		var delivered, pending, older int
+		for _, run := range runs {
+			complete, err := runComplete(rootDir, run)
+			if err != nil {
+				return err
+			}
+			switch {
+			case !complete:
+				pending++
+			case !since.IsZero() && (run.Date().IsZero() || run.Date().Before(since)):
+				older++
+			default:
+				if _, ok := registry.Delivered(run.Name, outputDir); ok {
+					delivered++
+				} else {
+					todo = append(todo, run)
+				}
+			}
+		}
+
+		metadata.SortRunsOldestFirst(todo)
+
+		fmt.Printf("Found %d runs: %d already delivered, %d pending or transferring", len(runs), delivered, pending)
+		if !since.IsZero() {
+			fmt.Printf(", %d before %s", older, ui.FormatTime(since))
+		}
+		fmt.Println()
+		if len(todo) == 0 {
+			ui.Green("Nothing to deliver.\n")
+			return nil
+		}
+		ui.Bold("Runs to deliver (%d):\n", len(todo))
+		for _, run := range todo {
+			fmt.Printf("  - %s (%d biosamples)\n", run.Name, run.BioSampleCount())
+		}
+
+		outcomes := make([]*runOutcome, len(todo))
+		for i, run := range todo {
+			outcomes[i] = &runOutcome{run: run, status: "not started"}
+		}
+		for i, run := range todo {
+			if cmd.Context().Err() != nil {
+				break
+			}
+			ui.Bold("\n=============== [%d/%d] %s ===============\n", i+1, len(todo), run.Name)
+			outcomes[i].setResult(deliverRun(cmd.Context(), run, outputDir))
+		}
+		return reportRuns("SYNC SUMMARY", outcomes, cmd.Context().Err())
+	},
+}
+
+// parseSince parses --since: a date, an RFC 3339 time, or a period before now
+// such as 48h or 7d. The zero time means no limit.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a date such as 2025-09-22, an RFC 3339 time, or a period such as 48h or 7d)", s)
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncSince, "since", "", "only runs started on or after this date (2025-09-22) or within this period (48h, 7d)")
+	rootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyManifest string
+	verifyJSON     string
+)
+
+// verifyReport is the machine-readable result of the verify command.
+type verifyReport struct {
+	Run       string                 `json:"run,omitempty"`
+	OutputDir string                 `json:"output_dir"`
+	Manifest  string                 `json:"manifest,omitempty"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Passed    int                    `json:"passed"`
+	Failed    int                    `json:"failed"`
+	Files     []*copyfiles.FileCheck `json:"files"`
+}
+
+// verifyCmd re-checks delivered files against the source run or the manifest
+var verifyCmd = &cobra.Command{
+	Use:   "verify [runs-directory]",
+	Short: "Re-check delivered files against the source run or the checksum manifest",
+	Long: `Verify a delivery. With a runs directory, the file mappings of --run are rebuilt and every
+destination under --output is compared with its source (existence, size and checksum). Files whose
+source is gone are checked against the manifest instead.
+
+Without a runs directory, every file recorded in the manifest (--manifest, default
+<output>/.revio-copy/manifest.json) is checked against its recorded size and checksum, so the
+instrument output does not need to be available.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputDir := flags.GetOutputDir()
+		manifestPath := verifyManifest
+		if manifestPath == "" {
+			if outputDir == "" {
+				return fmt.Errorf("--output is required")
+			}
+			manifestPath = copyfiles.ManifestPath(stateDir(outputDir))
+		}
+		manifest, err := copyfiles.LoadManifest(manifestPath)
+		if err != nil {
+			return err
+		}
+		hashes, err := copyfiles.ParseHashAlgorithms(flags.GetChecksums())
+		if err != nil {
+			return err
+		}
+		verifier := &copyfiles.Verifier{Jobs: flags.GetJobs(), Manifest: manifest}
+		if len(hashes) > 0 {
+			verifier.Hash = hashes[0]
+		}
+
+		report := &verifyReport{OutputDir: outputDir, Manifest: manifestPath, CheckedAt: time.Now()}
+		quiet := verifyJSON == "-"
+		if len(args) == 1 {
+			if outputDir == "" || flags.GetRunName() == "" {
+				return fmt.Errorf("--output and --run are required to verify against a runs directory")
+			}
+			mappings, err := verifyMappings(cmd, args[0], outputDir, quiet)
+			if err != nil {
+				return err
+			}
+			report.Run = flags.GetRunName()
+			report.Files, err = verifier.VerifyMappings(cmd.Context(), mappings)
+			if err != nil {
+				return err
+			}
+		} else {
+			if len(manifest.Entries) == 0 {
+				return fmt.Errorf("manifest %s has no entries", manifestPath)
+			}
+			if !quiet {
+				ui.Italic("Verifying %d files recorded in %s...\n", len(manifest.Entries), manifestPath)
+			}
+			report.Files, err = verifier.VerifyManifest(cmd.Context(), manifest)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, c := range report.Files {
+			if c.OK() {
+				report.Passed++
+			} else {
+				report.Failed++
+			}
+		}
+
+		if !quiet {
+			printVerifyTable(report, outputDir)
+		}
+		if verifyJSON != "" {
+			if err := writeVerifyJSON(report, verifyJSON); err != nil {
+				return err
+			}
+		}
+		if report.Failed > 0 {
+			return fmt.Errorf("%w: %d of %d files failed", copyfiles.ErrVerification, report.Failed, len(report.Files))
+		}
+		return nil
+	},
+}
+
+// verifyMappings rebuilds the file mappings of the selected run as process would.
+func verifyMappings(cmd *cobra.Command, rootDir, outputDir string, quiet bool) ([]*fileops.FileMapping, error) {
+	opts, err := identifyOptions(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if !quiet {
+		ui.Italic("Scanning for runs in %s...\n", rootDir)
+	}
+	run, err := metadata.FindRunsByName(cmd.Context(), rootDir, flags.GetRunName())
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := fileops.IdentifyAllHiFiFiles(cmd.Context(), run.Cells, opts)
+	if ctxErr := cmd.Context().Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		if len(mappings) == 0 {
+			return nil, err
+		}
+		ui.Warn("Some biosamples could not be identified and are not verified:\n%v\n", err)
+	}
+	if !quiet {
+		fmt.Printf("Verifying %d files of run %s...\n", countFiles(mappings), run.Name)
+	}
+	return mappings, nil
+}
+
+// printVerifyTable prints one line per file and a pass/fail summary.
+func printVerifyTable(report *verifyReport, outputDir string) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESULT\tSTATUS\tBIOSAMPLE\tFILE\tSIZE\tREFERENCE\tDETAIL")
+	for _, c := range report.Files {
+		result := "PASS"
+		if !c.OK() {
+			result = "FAIL"
+		}
+		name := c.Dest
+		if rel, err := filepath.Rel(outputDir, c.Dest); outputDir != "" && err == nil {
+			name = rel
+		}
+		detail := c.Detail
+		if c.Status == copyfiles.CheckSizeMismatch {
+			detail = fmt.Sprintf("expected %d bytes", c.ExpectedSize)
+		} else if c.Status == copyfiles.CheckChecksumMismatch {
+			detail = fmt.Sprintf("%s expected %s, got %s", c.Algorithm, c.Expected, c.Actual)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result, c.Status, valueOrDash(c.BioSample), name,
+			ui.FormatBytes(c.Size), valueOrDash(c.Reference), detail)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if report.Failed == 0 {
+		ui.Green("All %d files passed verification.\n", report.Passed)
+	} else {
+		ui.Red("%d of %d files failed verification.\n", report.Failed, len(report.Files))
+	}
+}
+
+// writeVerifyJSON writes the report as JSON to path, or to stdout for "-".
+func writeVerifyJSON(report *verifyReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing JSON report: %w", err)
+	}
+	fmt.Printf("JSON report written to %s\n", path)
+	return nil
+}
+
+// valueOrDash returns s, or "-" when s is empty.
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyManifest, "manifest", "", "manifest to verify against (default <output>/.revio-copy/manifest.json)")
+	verifyCmd.Flags().StringVar(&verifyJSON, "json", "", "write a JSON report to this file ('-' for stdout only)")
+	rootCmd.AddCommand(verifyCmd)
+}
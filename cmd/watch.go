@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/delivery"
+	"github.com/schnurbe/revio-copy/pkg/flags"
+	"github.com/schnurbe/revio-copy/pkg/logging"
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+	"github.com/schnurbe/revio-copy/pkg/watch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	watchPoll        bool
+	watchInterval    time.Duration
+	watchSettle      time.Duration
+	watchExisting    bool
+	watchMaxAttempts int
+	watchRetryDelay  time.Duration
+)
+
+// watchCmd delivers runs automatically when they complete
+var watchCmd = &cobra.Command{
+	Use:   "watch [directory]",
+	Short: "Keep running and deliver runs automatically when they complete",
+	Long: `Watch a runs directory and deliver every run that moves from pending (transfer markers but no
+metadata XML) to complete. Filesystem events are used where available; the directory is also
+rescanned every --interval, which is the only mechanism with --poll (e.g. on NFS).
+
+A run is delivered once it has stayed complete for --settle. Its output directory comes from the
+"routes" in the config file (first match wins), or --output when no route matches:
+
+  routes:
+    - run: "r84001_*"        # glob on the run name
+      biosample: "LAB1_*"    # any biosample of the run
+      instrument: "Revio1"   # instrument name or serial of any cell
+      output: /data/lab1
+    - run: "*_test_*"
+      skip: true
+
+The watch state (--state-dir, default <output>/.revio-copy) remembers which runs were seen and
+delivered, so a run is never delivered twice, also across restarts. Runs that are already complete
+when the state is created are not delivered unless --existing is given; use sync for the backlog.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if flags.GetStateDir() == "" && flags.GetOutputDir() == "" {
+			return fmt.Errorf("--output or --state-dir is required")
+		}
+		router, err := watchRouter()
+		if err != nil {
+			return err
+		}
+		if len(router.Routes) == 0 && router.Default == "" {
+			return fmt.Errorf("no routes in the config file and no --output: nothing would be delivered")
+		}
+		return validateCopyFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		router, err := watchRouter()
+		if err != nil {
+			return err
+		}
+		statePath := watch.StatePath(stateDir(flags.GetOutputDir()))
+		state, err := watch.LoadState(statePath)
+		if err != nil {
+			return err
+		}
+		w := &runWatcher{
+			root:   args[0],
+			router: router,
+			state:  state,
+			policy: watch.Policy{
+				Settle:      watchSettle,
+				MaxAttempts: watchMaxAttempts,
+				RetryDelay:  watchRetryDelay,
+				Existing:    watchExisting,
+			},
+			simulated: make(map[string]bool),
+		}
+
+		notifier := &watch.Notifier{Root: args[0], Interval: watchInterval, Poll: watchPoll}
+		changes, events := notifier.Start(cmd.Context())
+		mode := "polling every " + watchInterval.String()
+		if events {
+			mode = "filesystem events, rescanning every " + watchInterval.String()
+		}
+		ui.Bold("Watching %s (%s)\n", args[0], mode)
+		fmt.Printf("State: %s\n", statePath)
+
+		for range changes {
+			if err := w.scan(cmd.Context()); err != nil {
+				if cmd.Context().Err() != nil {
+					return err
+				}
+				ui.Red("Scan failed: %v\n", err)
+			}
+		}
+		fmt.Println("Stopped watching.")
+		return nil
+	},
+}
+
+// watchRouter builds the router from the config file routes and --output.
+func watchRouter() (*delivery.Router, error) {
+	var routes []delivery.Route
+	if err := viper.UnmarshalKey("routes", &routes); err != nil {
+		return nil, fmt.Errorf("invalid routes in config: %w", err)
+	}
+	router, err := delivery.NewRouter(routes, flags.GetOutputDir())
+	if err != nil {
+		return nil, fmt.Errorf("invalid routes in config: %w", err)
+	}
+	return router, nil
+}
+
+// runWatcher decides which runs to deliver on each scan.
+type runWatcher struct {
+	root      string
+	router    *delivery.Router
+	state     *watch.State
+	policy    watch.Policy
+	simulated map[string]bool // Runs already shown in dry-run mode
+}
+
+// scan updates the state of every run and delivers the runs that are ready.
+func (w *runWatcher) scan(ctx context.Context) error {
+	runs, err := metadata.GetAllRuns(ctx, w.root)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		logging.Debugf("watch: %v", err) // No runs yet
+	}
+
+	now := time.Now()
+	for _, run := range runs {
+		complete, err := runComplete(w.root, run)
+		if err != nil {
+			logging.Debugf("watch: %v", err)
+			continue
+		}
+		rs := w.state.Observe(run.Name, complete, now)
+		if !rs.Ready(now, w.policy) {
+			continue
+		}
+		outputDir, ok := w.router.OutputFor(run)
+		if !ok {
+			continue
+		}
+		if err := w.deliver(ctx, run, rs, outputDir); err != nil {
+			return err
+		}
+	}
+
+	w.state.Initialized = true
+	if flags.GetDryRunMode() {
+		return nil
+	}
+	return w.state.Save()
+}
+
+// deliver copies a ready run and records the outcome in the state. It only
+// returns an error when ctx was cancelled or the state could not be saved.
+func (w *runWatcher) deliver(ctx context.Context, run *metadata.RunInfo, rs *watch.RunState, outputDir string) error {
+	if flags.GetDryRunMode() {
+		if w.simulated[run.Name] {
+			return nil
+		}
+		w.simulated[run.Name] = true
+	} else {
+		registry, err := delivery.LoadRegistry(delivery.RegistryPath(stateDir(outputDir)))
+		if err != nil {
+			return err
+		}
+		if rec, ok := registry.Delivered(run.Name, outputDir); ok {
+			logging.Debugf("watch: run %s was delivered to %s at %s", run.Name, outputDir, rec.DeliveredAt)
+			rs.Delivered, rs.OutputDir = rec.DeliveredAt, outputDir
+			return w.state.Save()
+		}
+	}
+
+	ui.Bold("\n%s: run %s is complete, delivering to %s\n", ui.FormatTime(time.Now()), run.Name, outputDir)
+	err := deliverRun(ctx, run, outputDir)
+	if ctx.Err() != nil {
+		return errors.Join(ctx.Err(), err)
+	}
+	if flags.GetDryRunMode() {
+		return nil
+	}
+	rs.OutputDir, rs.LastAttempt = outputDir, time.Now()
+	if err != nil {
+		rs.Attempts++
+		rs.LastError = err.Error()
+		ui.Red("Delivery of run %s failed (attempt %d of %d): %v\n", run.Name, rs.Attempts, max(w.policy.MaxAttempts, 1), err)
+	} else {
+		rs.Delivered, rs.LastError = rs.LastAttempt, ""
+		ui.Green("Run %s delivered.\n", run.Name)
+	}
+	return w.state.Save()
+}
+
+// runComplete reports whether every cell of run has its metadata XML.
+func runComplete(rootDir string, run *metadata.RunInfo) (bool, error) {
+	if run.Status != metadata.RunComplete {
+		return false, nil
+	}
+	pending, err := pendingCells(rootDir, run)
+	return len(pending) == 0, err
+}
+
+func init() {
+	watchCmd.Flags().BoolVar(&watchPoll, "poll", false, "only rescan every --interval; do not use filesystem events (e.g. on NFS)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Minute, "rescan the runs directory at least this often")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 2*time.Minute, "how long a run must stay complete before it is delivered")
+	watchCmd.Flags().BoolVar(&watchExisting, "existing", false, "also deliver runs that were already complete when the watch state was created")
+	watchCmd.Flags().IntVar(&watchMaxAttempts, "max-attempts", 3, "give up on a run after this many failed deliveries")
+	watchCmd.Flags().DurationVar(&watchRetryDelay, "retry-delay", 15*time.Minute, "wait between failed delivery attempts")
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,121 @@
+package copyfiles
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+// Copier copies a single file to its destination. Implementations must verify
+// the copy before returning successfully.
+type Copier interface {
+	// Name identifies the backend in output and logs.
+	Name() string
+	// Available reports whether the backend can be used on this host.
+	Available() error
+	// CopyFile copies src to dest. The destination directory already exists.
+	// FileCopier passes a temporary name (see PartialPath) and renames the
+	// file into place once the copy is verified. Cancelling ctx aborts the copy.
+	CopyFile(ctx context.Context, src, dest string) (*FileResult, error)
+}
+
+// FileResult describes one verified copy.
+type FileResult struct {
+	Source    string
+	Dest      string
+	Bytes     int64
+	Checksums Checksums // Digests computed during the copy; may be empty for backends that do not expose them
+}
+
+// Recorder receives per-file copy events, e.g. to write a session journal.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	FileStarted(m *fileops.FileMapping, src, dest string)
+	FileDone(m *fileops.FileMapping, result *FileResult, skipped bool)
+	FileFailed(m *fileops.FileMapping, src, dest string, err error)
+}
+
+// ProgressRecorder is a Recorder that also follows the bytes of copies in
+// progress, e.g. for a progress bar. Backends report them when they can; the
+// native copier does, rclone does not.
+type ProgressRecorder interface {
+	Recorder
+	// FileProgress reports the bytes of src copied so far to dest.
+	FileProgress(m *fileops.FileMapping, src, dest string, bytes int64)
+}
+
+// progressKey is the context key of the byte progress callback.
+type progressKey struct{}
+
+// withProgress returns a context carrying report, which backends call with
+// the number of bytes copied so far of the current file.
+func withProgress(ctx context.Context, report func(bytes int64)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// progressFunc returns the byte progress callback of ctx, or nil.
+func progressFunc(ctx context.Context) func(bytes int64) {
+	report, _ := ctx.Value(progressKey{}).(func(bytes int64))
+	return report
+}
+
+// workerAware is implemented by backends whose settings depend on the number
+// of copies running at the same time. FileCopier sets it before copying.
+type workerAware interface {
+	setWorkers(n int)
+}
+
+// CopierOptions configures the copy backends.
+type CopierOptions struct {
+	Hashes  []HashAlgorithm // Digests computed while copying (native backend)
+	Verify  bool            // Re-read the destination after copying and compare digests (native backend)
+	Verbose bool
+	Jobs    int          // Number of concurrent copies; backends keep their output quiet when > 1 or with a ProgressRecorder
+	Limiter *RateLimiter // Global bandwidth limit shared by all workers; nil means unlimited
+}
+
+// StateDirName is the directory inside the output directory holding revio-copy state
+// (manifest and journals) unless another state directory is configured.
+const StateDirName = ".revio-copy"
+
+// DefaultStateDir returns the default state directory for an output directory.
+func DefaultStateDir(outputDir string) string {
+	return filepath.Join(outputDir, StateDirName)
+}
+
+// ManifestPath returns the location of the verified-copy manifest in a state directory.
+func ManifestPath(stateDir string) string {
+	return filepath.Join(stateDir, "manifest.json")
+}
+
+// Backend names accepted by NewCopier.
+const (
+	BackendAuto   = "auto"
+	BackendNative = "native"
+	BackendRclone = "rclone"
+)
+
+// NewCopier returns the named copy backend. "auto" picks rclone when it is
+// installed and the native Go copier otherwise.
+func NewCopier(backend string, opts CopierOptions) (Copier, error) {
+	if len(opts.Hashes) == 0 {
+		opts.Hashes = []HashAlgorithm{HashMD5}
+	}
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case BackendNative:
+		return NewNativeCopier(opts), nil
+	case BackendRclone:
+		return NewRcloneCopier(opts), nil
+	case BackendAuto, "":
+		rc := NewRcloneCopier(opts)
+		if rc.Available() == nil {
+			return rc, nil
+		}
+		return NewNativeCopier(opts), nil
+	default:
+		return nil, fmt.Errorf("unknown copy backend %q (want auto, native or rclone)", backend)
+	}
+}
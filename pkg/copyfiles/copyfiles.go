@@ -1,209 +1,456 @@
 package copyfiles
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sort"
+	"sync"
 
 	"github.com/schnurbe/revio-copy/pkg/fileops"
 )
 
-// FileCopier handles copying files with rclone.
+// FileCopier copies file mappings using a pluggable Copier backend.
 type FileCopier struct {
+	Backend Copier
 	DryRun  bool
 	Verbose bool
+	Jobs    int // Number of mappings copied concurrently; values < 1 mean 1
+
+	// Resume skips destinations that already hold a verified copy of their source.
+	Resume     bool
+	ResumeHash HashAlgorithm // Checksum used to compare existing files; default md5
+	Manifest   *Manifest     // Record of verified copies; nil disables the manifest
+
+	Recorder Recorder  // Receives per-file events (e.g. the session journal); may be nil
+	Out      io.Writer // Destination of progress messages; nil means stdout
+
+	// OnInterrupt decides whether in-flight copies finish or are aborted when
+	// the context passed to CopyAllFileMappings is cancelled; default finish.
+	OnInterrupt InterruptMode
+
+	abortMu sync.Mutex
+	abort   context.CancelFunc // Cancels in-flight copies of the running session
+
+	outMu   sync.Mutex // Serialises output lines from concurrent workers
+	countMu sync.Mutex
+	copied  int // Files copied during this session
+	skipped int // Files found already present during this session
+	failed  int // Files that failed during this session
+}
+
+// InterruptMode selects what happens to in-flight copies on cancellation.
+type InterruptMode string
+
+const (
+	// InterruptFinish lets in-flight copies complete and stops scheduling new ones.
+	InterruptFinish InterruptMode = "finish"
+	// InterruptAbort aborts in-flight copies and discards their partial files.
+	InterruptAbort InterruptMode = "abort"
+)
+
+// ParseInterruptMode validates an interrupt mode name; empty means InterruptFinish.
+func ParseInterruptMode(s string) (InterruptMode, error) {
+	switch InterruptMode(s) {
+	case "", InterruptFinish:
+		return InterruptFinish, nil
+	case InterruptAbort:
+		return InterruptAbort, nil
+	}
+	return "", fmt.Errorf("unknown interrupt mode %q (want finish or abort)", s)
+}
+
+// Abort cancels the in-flight copies of the running CopyAllFileMappings call,
+// e.g. on a second interrupt while waiting for them to finish.
+func (fc *FileCopier) Abort() {
+	fc.abortMu.Lock()
+	defer fc.abortMu.Unlock()
+	if fc.abort != nil {
+		fc.abort()
+	}
+}
+
+// Totals returns the number of files copied, skipped as already present, and failed so far.
+func (fc *FileCopier) Totals() (copied, skipped, failed int) {
+	fc.countMu.Lock()
+	defer fc.countMu.Unlock()
+	return fc.copied, fc.skipped, fc.failed
+}
+
+// count adds one to a session counter.
+func (fc *FileCopier) count(counter *int) {
+	fc.countMu.Lock()
+	*counter++
+	fc.countMu.Unlock()
 }
 
 // NewFileCopier creates a new FileCopier.
-func NewFileCopier(dryRun bool, verbose bool) *FileCopier {
+func NewFileCopier(backend Copier, dryRun bool, verbose bool) *FileCopier {
 	return &FileCopier{
+		Backend: backend,
 		DryRun:  dryRun,
 		Verbose: verbose,
+		Jobs:    1,
+	}
+}
+
+// concurrent reports whether several workers copy at once.
+func (fc *FileCopier) concurrent() bool { return fc.Jobs > 1 }
+
+// printf writes one complete message to Out without interleaving with other workers.
+func (fc *FileCopier) printf(format string, args ...interface{}) {
+	fc.outMu.Lock()
+	defer fc.outMu.Unlock()
+	out := fc.Out
+	if out == nil {
+		out = os.Stdout
 	}
+	fmt.Fprintf(out, format, args...)
 }
 
 // CopyFileMapping copies BAM + PBI for a mapping, creating destination directories.
-func (fc *FileCopier) CopyFileMapping(mapping *fileops.FileMapping) error {
+func (fc *FileCopier) CopyFileMapping(ctx context.Context, mapping *fileops.FileMapping) error {
+	return fc.copyFileMapping(ctx, mapping, "").Err
+}
+
+// copyFileMapping copies BAM + PBI for a mapping; prefix labels output lines when copying concurrently.
+// Both files are staged under their partial names and verified before either is
+// renamed into place, BAM first, so a sample is delivered all-or-nothing.
+func (fc *FileCopier) copyFileMapping(ctx context.Context, mapping *fileops.FileMapping, prefix string) *MappingResult {
+	res := &MappingResult{Mapping: mapping, Started: true}
+
 	// Create destination directory
 	destDir := filepath.Dir(mapping.DestBAM)
 	if !fc.DryRun {
 		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory: %w", err)
+			res.Err = fmt.Errorf("failed to create destination directory: %w", err)
+			return res
 		}
 	}
 
-	// Copy BAM file
-	if err := fc.copyFileRclone(mapping.SourceBAM, mapping.DestBAM); err != nil {
-		return fmt.Errorf("failed to copy BAM file: %w", err)
+	var staged []*FileResult
+	for _, f := range mapping.Files() {
+		result, skipped, err := fc.copyFile(ctx, mapping, prefix, f.Source, f.Dest)
+		if err != nil {
+			fc.discard(staged)
+			res.Err = fmt.Errorf("failed to copy %s file: %w", f.Kind, err)
+			return res
+		}
+		if result == nil {
+			continue // dry run
+		}
+		res.Files = append(res.Files, result)
+		if skipped {
+			res.SkippedBytes += result.Bytes
+		} else {
+			res.Bytes += result.Bytes
+			staged = append(staged, result)
+		}
 	}
 
-	// Copy PBI file
-	if err := fc.copyFileRclone(mapping.SourcePBI, mapping.DestPBI); err != nil {
-		return fmt.Errorf("failed to copy PBI file: %w", err)
+	if err := ctx.Err(); err != nil && len(staged) > 0 {
+		fc.discard(staged)
+		res.Err = fmt.Errorf("%w: %w", ErrInterrupted, err)
+		return res
+	}
+	for i, result := range staged {
+		if err := fc.commit(mapping, prefix, result); err != nil {
+			fc.discard(staged[i+1:])
+			res.Err = err
+			return res
+		}
 	}
 
-	return nil
+	return res
 }
 
-// CopyAllFileMappings copies all provided mappings sequentially.
-func (fc *FileCopier) CopyAllFileMappings(mappings []*fileops.FileMapping) error {
-	totalFiles := len(mappings) * 2 // BAM + PBI
-	completedFiles := 0
-
-	fmt.Printf("Starting copy of %d files (%d BAM + %d PBI)...\n",
-		totalFiles, len(mappings), len(mappings))
-
-	for i, mapping := range mappings {
-		fmt.Printf("\n[%d/%d] Processing biosample: %s\n",
-			i+1, len(mappings), mapping.BioSample)
+// commit renames a staged copy into place and records it.
+func (fc *FileCopier) commit(mapping *fileops.FileMapping, prefix string, result *FileResult) error {
+	if err := os.Rename(PartialPath(result.Dest), result.Dest); err != nil {
+		err = fmt.Errorf("failed to move %s into place: %w", filepath.Base(result.Dest), err)
+		fc.fail(mapping, result.Source, result.Dest, err)
+		return err
+	}
+	fc.count(&fc.copied)
+	if fc.Recorder != nil {
+		fc.Recorder.FileDone(mapping, result, false)
+	}
+	if err := fc.Manifest.Record(result); err != nil {
+		fc.printf("  %sWarning: could not update manifest: %v\n", prefix, err)
+	}
 
-		err := fc.CopyFileMapping(mapping)
-		if err != nil {
-			fmt.Printf("Error copying files for biosample %s: %v\n",
-				mapping.BioSample, err)
-			continue
+	fc.printf("  %s✓ Copy successful and verified: %s (%.2f MB)\n", prefix, filepath.Base(result.Dest), float64(result.Bytes)/(1024*1024))
+	if fc.Verbose {
+		for algo, sum := range result.Checksums {
+			fc.printf("    %s%s: %s\n", prefix, algo, sum)
 		}
-
-		completedFiles += 2 // BAM + PBI
-		fmt.Printf("Progress: %d/%d files completed (%.1f%%)\n",
-			completedFiles, totalFiles, float64(completedFiles)/float64(totalFiles)*100)
 	}
-
-	fmt.Printf("\nCopy operation completed. %d/%d files copied successfully.\n",
-		completedFiles, totalFiles)
-
 	return nil
 }
 
-// CopyHiFiReads copies HiFi reads BAM and PBI files to the output directory (legacy helper; prefer Identify + CopyFileMapping pipeline).
-func (fc *FileCopier) CopyHiFiReads(metadataPath, biosample, outputDir string) error {
-	// Determine source directory - metadata file is in the metadata subdir
-	metadataDir := filepath.Dir(metadataPath)
-	runDir := filepath.Dir(metadataDir) // Go up one level from metadata
-
-	// Source directory for HiFi reads
-	hifiDir := filepath.Join(runDir, "hifi_reads")
-
-	// Check if hifi_reads directory exists
-	if _, err := os.Stat(hifiDir); os.IsNotExist(err) {
-		return fmt.Errorf("hifi_reads directory not found at %s", hifiDir)
+// discard removes staged copies that will not be renamed into place.
+func (fc *FileCopier) discard(staged []*FileResult) {
+	for _, result := range staged {
+		os.Remove(PartialPath(result.Dest))
 	}
+}
 
-	// Find .bam and .bam.pbi files
-	bamFiles, err := filepath.Glob(filepath.Join(hifiDir, "*.hifi_reads.bam"))
-	if err != nil {
-		return err
+// scheduleLargestFirst returns the mappings ordered by total source size, largest
+// first, so a big file is never the last one started.
+func scheduleLargestFirst(mappings []*fileops.FileMapping) []*fileops.FileMapping {
+	sizes := make(map[*fileops.FileMapping]int64, len(mappings))
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			if info, err := os.Stat(f.Source); err == nil {
+				sizes[m] += info.Size()
+			}
+		}
 	}
+	ordered := append([]*fileops.FileMapping(nil), mappings...)
+	sort.SliceStable(ordered, func(i, j int) bool { return sizes[ordered[i]] > sizes[ordered[j]] })
+	return ordered
+}
 
-	if len(bamFiles) == 0 {
-		return fmt.Errorf("no HiFi BAM files found in %s", hifiDir)
+// CopyAllFileMappings copies all provided mappings using up to Jobs concurrent workers.
+// It always returns the per-mapping result; the error joins the failures of all
+// mappings that were not delivered (see CopyResult.Err).
+//
+// Cancelling ctx stops scheduling further mappings. In-flight copies finish or
+// are aborted according to OnInterrupt; aborted copies leave no partial files.
+func (fc *FileCopier) CopyAllFileMappings(ctx context.Context, mappings []*fileops.FileMapping) (*CopyResult, error) {
+	kinds := make(map[string]int)
+	totalFiles := 0
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			kinds[f.Kind]++
+			totalFiles++
+		}
+	}
+	completedFiles := 0
+	jobs := min(max(fc.Jobs, 1), max(len(mappings), 1))
+	if b, ok := fc.Backend.(workerAware); ok {
+		b.setWorkers(jobs)
 	}
+	copied0, skipped0, failed0 := fc.Totals()
 
-	// Destination directory for this sample
-	destDir := filepath.Join(outputDir, fmt.Sprintf("Sample_%s", biosample))
+	start := fmt.Sprintf("Starting copy of %d files (%d BAM + %d PBI", totalFiles, kinds["BAM"], kinds["PBI"])
+	if kinds["FILE"] > 0 {
+		start += fmt.Sprintf(" + %d other", kinds["FILE"])
+	}
+	start += ")"
+	if jobs > 1 {
+		start += fmt.Sprintf(" with %d parallel jobs", jobs)
+	}
+	fc.printf("%s...\n", start)
 
-	// Create destination directory
 	if !fc.DryRun {
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return err
+		removed, err := CleanPartials(mappings)
+		for _, path := range removed {
+			fc.printf("Removed stale partial file from an earlier session: %s\n", path)
+		}
+		if err != nil {
+			fc.printf("Warning: could not clean up partial files: %v\n", err)
 		}
 	}
 
-	// For each BAM file, copy BAM and its PBI
-	for _, bamFile := range bamFiles {
-		// Get corresponding PBI file
-		pbiFile := bamFile + ".pbi"
-		if _, err := os.Stat(pbiFile); os.IsNotExist(err) {
-			return fmt.Errorf("PBI file not found for BAM: %s", bamFile)
-		}
+	ordered := mappings
+	if jobs > 1 {
+		ordered = scheduleLargestFirst(mappings)
+	}
 
-		// Target filenames
-		destBam := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam", biosample))
-		destPbi := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam.pbi", biosample))
+	// In-flight copies outlive ctx unless aborted
+	copyCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+	fc.abortMu.Lock()
+	fc.abort = abort
+	fc.abortMu.Unlock()
+	defer func() {
+		fc.abortMu.Lock()
+		fc.abort = nil
+		fc.abortMu.Unlock()
+	}()
+	if fc.OnInterrupt == InterruptAbort {
+		stop := context.AfterFunc(ctx, abort)
+		defer stop()
+	}
 
-		// Copy BAM file
-		if err := fc.copyFileRclone(bamFile, destBam); err != nil {
-			return err
-		}
+	results := make(map[*fileops.FileMapping]*MappingResult, len(mappings))
+	type task struct {
+		index   int
+		mapping *fileops.FileMapping
+	}
+	tasks := make(chan task)
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				prefix := ""
+				if fc.concurrent() {
+					prefix = fmt.Sprintf("[%s] ", t.mapping.Label())
+					fc.printf("[%d/%d] Starting %s\n", t.index, len(ordered), describeMapping(t.mapping))
+				} else {
+					fc.printf("\n[%d/%d] Processing %s\n", t.index, len(ordered), describeMapping(t.mapping))
+				}
+
+				res := fc.copyFileMapping(copyCtx, t.mapping, prefix)
+				progressMu.Lock()
+				results[t.mapping] = res
+				if res.OK() {
+					completedFiles += len(t.mapping.Files())
+				}
+				done := completedFiles
+				progressMu.Unlock()
+
+				if !res.OK() {
+					fc.printf("%sError copying files for %s: %v\n",
+						prefix, describeMapping(t.mapping), res.Err)
+					continue
+				}
+				fc.printf("%sProgress: %d/%d files completed (%.1f%%)\n",
+					prefix, done, totalFiles, float64(done)/float64(totalFiles)*100)
+			}
+		}()
+	}
 
-		// Copy PBI file
-		if err := fc.copyFileRclone(pbiFile, destPbi); err != nil {
-			return err
+schedule:
+	for i, mapping := range ordered {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case tasks <- task{index: i + 1, mapping: mapping}:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	result := &CopyResult{Interrupted: ctx.Err() != nil}
+	if result.Interrupted && !fc.DryRun {
+		// Backends may leave their own temporary files when stopped
+		removed, _ := CleanPartials(mappings)
+		for _, path := range removed {
+			fc.printf("Removed partial file: %s\n", path)
+		}
+	}
+	for _, m := range mappings {
+		res, ok := results[m]
+		if !ok {
+			res = &MappingResult{Mapping: m, Err: fmt.Errorf("%w: not started", ErrInterrupted)}
 		}
+		result.Mappings = append(result.Mappings, res)
+		result.Bytes += res.Bytes
+		result.SkippedBytes += res.SkippedBytes
+	}
+	copied, skipped, failed := fc.Totals()
+	result.Copied, result.Skipped, result.Failed = copied-copied0, skipped-skipped0, failed-failed0
+
+	if result.Interrupted {
+		fc.printf("\nCopy operation interrupted. %d/%d files copied successfully.\n",
+			completedFiles, totalFiles)
+	} else {
+		fc.printf("\nCopy operation completed. %d/%d files copied successfully.\n",
+			completedFiles, totalFiles)
+	}
+	if result.Skipped > 0 {
+		fc.printf("%d files were already present and verified.\n", result.Skipped)
+	}
+	if failures := result.Failures(); len(failures) > 0 && !result.Interrupted {
+		fc.printf("%d of %d biosamples failed.\n", len(failures), len(mappings))
 	}
 
-	return nil
+	return result, result.Err()
 }
 
-// copyFileRclone uses rclone to copy a file with checksum verification.
-func (fc *FileCopier) copyFileRclone(src, dest string) error {
+// describeMapping names a mapping in progress output and errors, e.g.
+// "biosample S1" or "statistics ccs_report.json".
+func describeMapping(m *fileops.FileMapping) string {
+	if m.IsHiFi() {
+		return "biosample " + m.Label()
+	}
+	return m.Label()
+}
+
+// copyFile copies a single file with the configured backend to its partial name.
+// It returns the staged or already present file (nil in dry-run mode) and
+// whether it was already present; staged files are renamed by commit.
+func (fc *FileCopier) copyFile(ctx context.Context, mapping *fileops.FileMapping, prefix, src, dest string) (*FileResult, bool, error) {
 	// Check if source file exists
 	srcInfo, err := os.Stat(src)
 	if err != nil {
-		return fmt.Errorf("source file error: %w", err)
+		err = sourceError(err)
+		if !fc.DryRun {
+			fc.fail(mapping, src, dest, err)
+		}
+		return nil, false, err
 	}
 
 	// Get file size for display
-	srcSize := srcInfo.Size()
-	srcSizeMB := float64(srcSize) / (1024 * 1024)
-
-	// Prepare rclone command
-	args := []string{
-		"copyto",
-		"--checksum", // Verify checksums for data integrity
-		"--progress", // Show progress
-	}
-
-	// Add source and destination
-	args = append(args, src, dest)
+	srcSizeMB := float64(srcInfo.Size()) / (1024 * 1024)
 
-	// Add --dry-run flag if necessary
+	// Log the operation
 	if fc.DryRun {
-		args = append([]string{"--dry-run"}, args...)
+		fc.printf("  %s[DRY RUN] Would copy with %s: %s (%.2f MB) -> %s\n",
+			prefix, fc.Backend.Name(), filepath.Base(src), srcSizeMB, filepath.Base(dest))
+		return nil, false, nil
 	}
 
-	// Log the operation
-	if fc.DryRun {
-		fmt.Printf("  [DRY RUN] Would copy: %s (%.2f MB) -> %s\n",
-			filepath.Base(src), srcSizeMB, filepath.Base(dest))
-		if fc.Verbose {
-			fmt.Printf("  [DRY RUN] Command: rclone %s\n", strings.Join(args, " "))
+	if fc.Resume {
+		done, how, err := fc.checkExisting(src, dest)
+		if err != nil && !done {
+			fc.fail(mapping, src, dest, err)
+			return nil, false, err
+		}
+		if done {
+			fc.count(&fc.skipped)
+			result := &FileResult{Source: src, Dest: dest, Bytes: srcInfo.Size()}
+			if fc.Recorder != nil {
+				fc.Recorder.FileDone(mapping, result, true)
+			}
+			fc.printf("  %s✓ Already present, verified (%s): %s\n", prefix, how, filepath.Base(dest))
+			return result, true, nil
+		}
+		if how != "" {
+			fc.printf("  %sExisting destination does not match (%s), copying again: %s\n", prefix, how, filepath.Base(dest))
 		}
-		return nil
 	}
 
 	// In actual copy mode
-	fmt.Printf("  Copying: %s (%.2f MB) -> %s\n",
-		filepath.Base(src), srcSizeMB, filepath.Base(dest))
-
-	// Execute rclone command
-	cmd := exec.Command("rclone", args...)
+	fc.printf("  %sCopying: %s (%.2f MB) -> %s\n",
+		prefix, filepath.Base(src), srcSizeMB, filepath.Base(dest))
 
-	// Always show output for progress monitoring
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("rclone error: %w", err)
+	if fc.Recorder != nil {
+		fc.Recorder.FileStarted(mapping, src, dest)
 	}
-
-	// Verify destination file exists and has correct size
-	if !fc.DryRun {
-		destInfo, err := os.Stat(dest)
-		if err != nil {
-			return fmt.Errorf("destination verification failed: %w", err)
+	if pr, ok := fc.Recorder.(ProgressRecorder); ok {
+		ctx = withProgress(ctx, func(bytes int64) { pr.FileProgress(mapping, src, dest, bytes) })
+	}
+	partial := PartialPath(dest)
+	result, err := fc.Backend.CopyFile(ctx, src, partial)
+	if err != nil {
+		os.Remove(partial)
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", ErrInterrupted, err)
 		}
+		fc.fail(mapping, src, dest, err)
+		return nil, false, err
+	}
+	result.Dest = dest
 
-		if destInfo.Size() != srcInfo.Size() {
-			return fmt.Errorf("size mismatch: source=%d bytes, destination=%d bytes",
-				srcInfo.Size(), destInfo.Size())
-		}
+	return result, false, nil
+}
 
-		fmt.Printf("  ✓ Copy successful and verified (%.2f MB)\n", srcSizeMB)
+// fail counts and records a failed file copy.
+func (fc *FileCopier) fail(mapping *fileops.FileMapping, src, dest string, err error) {
+	fc.count(&fc.failed)
+	if fc.Recorder != nil {
+		fc.Recorder.FileFailed(mapping, src, dest, err)
 	}
-
-	return nil
 }
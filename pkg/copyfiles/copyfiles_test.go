@@ -0,0 +1,656 @@
+package copyfiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+// writeTestFile creates a file with content under dir and returns its path.
+func writeTestFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNativeCopierChecksums(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("ACGT"), 1<<18)
+	src := writeTestFile(t, dir, "src.bam", content)
+	dest := filepath.Join(dir, "dest.bam")
+
+	nc := NewNativeCopier(CopierOptions{Hashes: []HashAlgorithm{HashMD5, HashSHA256, HashXXH64}, Verify: true})
+	result, err := nc.CopyFile(context.Background(), src, dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Bytes != int64(len(content)) {
+		t.Fatalf("expected %d bytes got %d", len(content), result.Bytes)
+	}
+	want := md5.Sum(content)
+	if result.Checksums[HashMD5] != hex.EncodeToString(want[:]) {
+		t.Fatalf("unexpected md5 %s", result.Checksums[HashMD5])
+	}
+	if len(result.Checksums[HashSHA256]) != 64 || len(result.Checksums[HashXXH64]) != 16 {
+		t.Fatalf("unexpected checksums %+v", result.Checksums)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("destination content differs (err=%v)", err)
+	}
+}
+
+func TestParseHashAlgorithms(t *testing.T) {
+	algos, err := ParseHashAlgorithms("md5, SHA256,md5")
+	if err != nil || len(algos) != 2 || algos[1] != HashSHA256 {
+		t.Fatalf("unexpected result %v %v", algos, err)
+	}
+	if _, err := ParseHashAlgorithms("crc32"); err == nil {
+		t.Fatal("expected error for unsupported algorithm")
+	}
+}
+
+func TestCopyAllFileMappingsNative(t *testing.T) {
+	dir := t.TempDir()
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "Sample_A", "A.mod.unmapped.bam"),
+		DestPBI:   filepath.Join(dir, "out", "Sample_A", "A.mod.unmapped.bam.pbi"),
+		BioSample: "A",
+	}
+
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{Verify: true}), false, false)
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range []string{mapping.DestBAM, mapping.DestPBI} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %s to exist: %v", p, err)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{"": 0, "512": 512, "64k": 64 << 10, "100M": 100 << 20, "1.5G": 3 << 29, "2GiB": 2 << 30}
+	for in, want := range tests {
+		got, err := ParseByteSize(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"B", "10X", "-5M"} {
+		if _, err := ParseByteSize(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestRcloneBandwidthLimit(t *testing.T) {
+	rc := NewRcloneCopier(CopierOptions{Jobs: 4, Limiter: NewRateLimiter(8 << 20)})
+	for _, tt := range []struct {
+		workers int
+		want    string
+	}{{4, "2048k"}, {2, "4096k"}, {1, "8192k"}} {
+		rc.setWorkers(tt.workers)
+		args := strings.Join(rc.args(context.Background(), "src", "dest"), " ")
+		if !strings.Contains(args, "--bwlimit "+tt.want+" ") {
+			t.Fatalf("expected --bwlimit %s with %d workers, got %s", tt.want, tt.workers, args)
+		}
+	}
+
+	// A session of two mappings runs two copies even with more jobs.
+	dir := t.TempDir()
+	fc := NewFileCopier(rc, true, false)
+	fc.Jobs = 4
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{
+		{
+			SourceBAM: writeTestFile(t, dir, "a.bam", nil), DestBAM: filepath.Join(dir, "out", "a.bam"),
+			SourcePBI: writeTestFile(t, dir, "a.bam.pbi", nil), DestPBI: filepath.Join(dir, "out", "a.bam.pbi"),
+		},
+		{
+			SourceBAM: writeTestFile(t, dir, "b.bam", nil), DestBAM: filepath.Join(dir, "out", "b.bam"),
+			SourcePBI: writeTestFile(t, dir, "b.bam.pbi", nil), DestPBI: filepath.Join(dir, "out", "b.bam.pbi"),
+		},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.workers != 2 {
+		t.Fatalf("expected 2 workers, got %d", rc.workers)
+	}
+}
+
+func TestRcloneQuietWithProgress(t *testing.T) {
+	rc := NewRcloneCopier(CopierOptions{Jobs: 1})
+	if args := rc.args(context.Background(), "src", "dest"); !slices.Contains(args, "--progress") {
+		t.Fatalf("expected --progress for a single job, got %v", args)
+	}
+	ctx := withProgress(context.Background(), func(int64) {})
+	if args := rc.args(ctx, "src", "dest"); slices.Contains(args, "--progress") {
+		t.Fatalf("expected no --progress below a progress bar, got %v", args)
+	}
+}
+
+func TestCopyAllFileMappingsParallel(t *testing.T) {
+	dir := t.TempDir()
+	var mappings []*fileops.FileMapping
+	for i, name := range []string{"A", "B", "C", "D"} {
+		content := bytes.Repeat([]byte{byte('a' + i)}, (i+1)*1000)
+		mappings = append(mappings, &fileops.FileMapping{
+			SourceBAM: writeTestFile(t, dir, "src/"+name+".bam", content),
+			SourcePBI: writeTestFile(t, dir, "src/"+name+".bam.pbi", []byte(name)),
+			DestBAM:   filepath.Join(dir, "out", "Sample_"+name, name+".bam"),
+			DestPBI:   filepath.Join(dir, "out", "Sample_"+name, name+".bam.pbi"),
+			BioSample: name,
+		})
+	}
+
+	ordered := scheduleLargestFirst(mappings)
+	if ordered[0].BioSample != "D" || ordered[3].BioSample != "A" {
+		t.Fatalf("expected largest first, got %s..%s", ordered[0].BioSample, ordered[3].BioSample)
+	}
+
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{Jobs: 3, Limiter: NewRateLimiter(1 << 30)}), false, false)
+	fc.Jobs = 3
+	if _, err := fc.CopyAllFileMappings(context.Background(), mappings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range mappings {
+		if _, err := os.Stat(m.DestBAM); err != nil {
+			t.Fatalf("expected %s to exist: %v", m.DestBAM, err)
+		}
+	}
+}
+
+func TestCopyAllFileMappingsResume(t *testing.T) {
+	dir := t.TempDir()
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam-content")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "Sample_A", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "Sample_A", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	// A previous session left a complete BAM and a truncated PBI behind.
+	writeTestFile(t, dir, "out/Sample_A/A.bam", []byte("bam-content"))
+	writeTestFile(t, dir, "out/Sample_A/A.bam.pbi", []byte("p"))
+
+	manifest, err := LoadManifest(ManifestPath(DefaultStateDir(filepath.Join(dir, "out"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{Verify: true}), false, false)
+	fc.Resume = true
+	fc.Manifest = manifest
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.skipped != 1 {
+		t.Fatalf("expected the BAM to be skipped, skipped=%d", fc.skipped)
+	}
+	if got, _ := os.ReadFile(mapping.DestPBI); string(got) != "pbi" {
+		t.Fatalf("expected truncated PBI to be copied again, got %q", got)
+	}
+
+	// Both files are now in the manifest and are skipped without hashing.
+	reloaded, err := LoadManifest(ManifestPath(DefaultStateDir(filepath.Join(dir, "out"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Lookup(mapping.DestPBI); !ok {
+		t.Fatal("expected PBI to be recorded in the manifest")
+	}
+	fc = NewFileCopier(NewNativeCopier(CopierOptions{}), false, false)
+	fc.Resume = true
+	fc.Manifest = reloaded
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.skipped != 2 {
+		t.Fatalf("expected both files to be skipped, skipped=%d", fc.skipped)
+	}
+
+	// A regenerated source of the same size is compared again, not trusted from the manifest.
+	writeTestFile(t, dir, "src/a.bam", []byte("BAM-content"))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(mapping.SourceBAM, later, later); err != nil {
+		t.Fatal(err)
+	}
+	fc = NewFileCopier(NewNativeCopier(CopierOptions{}), false, false)
+	fc.Resume = true
+	fc.Manifest = reloaded
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := os.ReadFile(mapping.DestBAM); string(got) != "BAM-content" {
+		t.Fatalf("expected changed source to be copied again, got %q", got)
+	}
+}
+
+func TestCopyAllFileMappingsResult(t *testing.T) {
+	dir := t.TempDir()
+	good := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam-content")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "Sample_A", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "Sample_A", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	missing := &fileops.FileMapping{
+		SourceBAM: filepath.Join(dir, "src", "b.bam"),
+		SourcePBI: filepath.Join(dir, "src", "b.bam.pbi"),
+		DestBAM:   filepath.Join(dir, "out", "Sample_B", "B.bam"),
+		DestPBI:   filepath.Join(dir, "out", "Sample_B", "B.bam.pbi"),
+		BioSample: "B",
+	}
+
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{}), false, false)
+	result, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{good, missing})
+	if err == nil {
+		t.Fatal("expected an error for the missing source")
+	}
+	if !errors.Is(err, ErrSourceMissing) {
+		t.Fatalf("expected ErrSourceMissing, got %v", err)
+	}
+	var merr *MappingError
+	if !errors.As(err, &merr) || merr.Mapping != missing {
+		t.Fatalf("expected a MappingError for biosample B, got %v", err)
+	}
+	if result.Succeeded() != 1 || len(result.Failures()) != 1 || result.Mappings[1].Mapping != missing {
+		t.Fatalf("unexpected per-mapping results: %+v", result.Mappings)
+	}
+	if result.Copied != 2 || result.Failed != 1 || result.Bytes != int64(len("bam-content")+len("pbi")) {
+		t.Fatalf("unexpected totals: copied=%d failed=%d bytes=%d", result.Copied, result.Failed, result.Bytes)
+	}
+}
+
+// corruptingCopier copies files but reports a checksum mismatch.
+// progressRecorder keeps the last byte count reported per destination.
+type progressRecorder struct {
+	mu    sync.Mutex
+	bytes map[string]int64
+}
+
+func (r *progressRecorder) FileStarted(m *fileops.FileMapping, src, dest string)              {}
+func (r *progressRecorder) FileDone(m *fileops.FileMapping, result *FileResult, skipped bool) {}
+func (r *progressRecorder) FileFailed(m *fileops.FileMapping, src, dest string, err error)    {}
+func (r *progressRecorder) FileProgress(m *fileops.FileMapping, src, dest string, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes[dest] = bytes
+}
+
+func TestCopyReportsProgress(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("x"), 3*copyBufferSize/2)
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", content),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	rec := &progressRecorder{bytes: make(map[string]int64)}
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{}), false, false)
+	fc.Recorder = rec
+	fc.Out = io.Discard
+	if err := fc.CopyFileMapping(context.Background(), mapping); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.bytes[mapping.DestBAM] != int64(len(content)) || rec.bytes[mapping.DestPBI] != 3 {
+		t.Fatalf("unexpected progress: %v", rec.bytes)
+	}
+}
+
+type corruptingCopier struct{ NativeCopier }
+
+func (c *corruptingCopier) CopyFile(ctx context.Context, src, dest string) (*FileResult, error) {
+	return nil, fmt.Errorf("%w: md5 mismatch", ErrVerification)
+}
+
+func TestCopyAllFileMappingsVerificationFailure(t *testing.T) {
+	dir := t.TempDir()
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam-content")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	fc := NewFileCopier(&corruptingCopier{}, false, false)
+	result, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping})
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("expected ErrVerification, got %v", err)
+	}
+	if result.Succeeded() != 0 {
+		t.Fatalf("expected no successful mapping, got %d", result.Succeeded())
+	}
+}
+
+// failingCopier writes part of the file and then fails.
+type failingCopier struct{ NativeCopier }
+
+func (c *failingCopier) CopyFile(ctx context.Context, src, dest string) (*FileResult, error) {
+	if filepath.Ext(src) == ".pbi" {
+		os.WriteFile(dest, []byte("half"), 0644)
+		return nil, fmt.Errorf("disk full")
+	}
+	return c.NativeCopier.CopyFile(ctx, src, dest)
+}
+
+func TestCopyFileMappingAtomic(t *testing.T) {
+	dir := t.TempDir()
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam-content")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	if got := PartialPath(mapping.DestBAM); got != filepath.Join(dir, "out", ".A.bam.partial") {
+		t.Fatalf("unexpected partial path %s", got)
+	}
+
+	// A failing PBI copy leaves neither file behind.
+	fc := NewFileCopier(&failingCopier{}, false, false)
+	if err := fc.CopyFileMapping(context.Background(), mapping); err == nil {
+		t.Fatal("expected PBI copy to fail")
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "out"))
+	if len(entries) != 0 {
+		t.Fatalf("expected no files after failed delivery, found %d (%s)", len(entries), entries[0].Name())
+	}
+
+	// A stale partial from a crashed session is removed on the next run.
+	stale := writeTestFile(t, dir, "out/.B.bam.partial", []byte("stale"))
+	fc = NewFileCopier(NewNativeCopier(CopierOptions{}), false, false)
+	if _, err := fc.CopyAllFileMappings(context.Background(), []*fileops.FileMapping{mapping}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale partial to be removed, got %v", err)
+	}
+	for _, p := range []string{mapping.DestBAM, mapping.DestPBI} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %s to be delivered: %v", p, err)
+		}
+		if _, err := os.Stat(PartialPath(p)); !os.IsNotExist(err) {
+			t.Fatalf("expected no partial left for %s", p)
+		}
+	}
+}
+
+// blockingCopier signals when a copy starts and then waits for cancellation or release.
+type blockingCopier struct {
+	NativeCopier
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCopier) CopyFile(ctx context.Context, src, dest string) (*FileResult, error) {
+	c.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		os.WriteFile(dest, []byte("half"), 0644)
+		return nil, ctx.Err()
+	case <-c.release:
+		return c.NativeCopier.CopyFile(ctx, src, dest)
+	}
+}
+
+func TestCopyAllFileMappingsInterrupt(t *testing.T) {
+	for _, mode := range []InterruptMode{InterruptFinish, InterruptAbort} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			var mappings []*fileops.FileMapping
+			for _, name := range []string{"A", "B"} {
+				mappings = append(mappings, &fileops.FileMapping{
+					SourceBAM: writeTestFile(t, dir, "src/"+name+".bam", []byte("bam-"+name)),
+					SourcePBI: writeTestFile(t, dir, "src/"+name+".bam.pbi", []byte("pbi-"+name)),
+					DestBAM:   filepath.Join(dir, "out", name+".bam"),
+					DestPBI:   filepath.Join(dir, "out", name+".bam.pbi"),
+					BioSample: name,
+				})
+			}
+
+			backend := &blockingCopier{started: make(chan struct{}), release: make(chan struct{})}
+			fc := NewFileCopier(backend, false, false)
+			fc.OnInterrupt = mode
+			ctx, cancel := context.WithCancel(context.Background())
+
+			type outcome struct {
+				result *CopyResult
+				err    error
+			}
+			done := make(chan outcome)
+			go func() {
+				result, err := fc.CopyAllFileMappings(ctx, mappings)
+				done <- outcome{result, err}
+			}()
+
+			<-backend.started // BAM of A is in flight
+			cancel()
+			if mode == InterruptFinish {
+				close(backend.release)
+				<-backend.started // PBI of A still follows
+			}
+			out := <-done
+
+			if !out.result.Interrupted || !errors.Is(out.err, ErrInterrupted) {
+				t.Fatalf("expected an interrupted result, got %v", out.err)
+			}
+			if out.result.Mappings[1].Started {
+				t.Fatal("expected B not to be started")
+			}
+			_, errA := os.Stat(mappings[0].DestPBI)
+			if mode == InterruptFinish && (!out.result.Mappings[0].OK() || errA != nil) {
+				t.Fatalf("expected A to finish, got %v / %v", out.result.Mappings[0].Err, errA)
+			}
+			if mode == InterruptAbort && out.result.Mappings[0].OK() {
+				t.Fatal("expected A to be aborted")
+			}
+			entries, _ := os.ReadDir(filepath.Join(dir, "out"))
+			for _, e := range entries {
+				if isPartialName(e.Name()) {
+					t.Fatalf("partial file left behind: %s", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestParseSpaceMargin(t *testing.T) {
+	cases := map[string]SpaceMargin{
+		"5%":   {Percent: 5},
+		"0":    {},
+		"100G": {Bytes: 100 << 30},
+	}
+	for in, want := range cases {
+		got, err := ParseSpaceMargin(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseSpaceMargin(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	if _, err := ParseSpaceMargin("x%"); err == nil {
+		t.Fatal("expected error for invalid percentage")
+	}
+	if got := (SpaceMargin{Bytes: 10, Percent: 50}).For(100); got != 60 {
+		t.Fatalf("expected margin 60, got %d", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	mapping := &fileops.FileMapping{
+		SourceBAM: writeTestFile(t, dir, "src/a.bam", []byte("bam-content")),
+		SourcePBI: writeTestFile(t, dir, "src/a.bam.pbi", []byte("pbi")),
+		DestBAM:   filepath.Join(dir, "out", "Sample_A", "A.bam"),
+		DestPBI:   filepath.Join(dir, "out", "Sample_A", "A.bam.pbi"),
+		BioSample: "A",
+	}
+	writeTestFile(t, dir, "out/Sample_A/A.bam.pbi", []byte("pbi"))
+
+	report := Preflight([]*fileops.FileMapping{mapping}, SpaceMargin{Percent: 5}, true)
+	if !report.OK() {
+		t.Fatalf("unexpected problems: %v", report.Problems)
+	}
+	if report.Total != 14 || report.Present != 3 {
+		t.Fatalf("expected total 14 and present 3, got %d and %d", report.Total, report.Present)
+	}
+	if len(report.Volumes) != 1 || report.Volumes[0].Needed != 11 {
+		t.Fatalf("unexpected volumes: %+v", report.Volumes)
+	}
+
+	// Without --skip-existing the present PBI is copied again and needs its space.
+	report = Preflight([]*fileops.FileMapping{mapping}, SpaceMargin{Percent: 5}, false)
+	if report.Present != 0 || len(report.Volumes) != 1 || report.Volumes[0].Needed != 14 {
+		t.Fatalf("expected nothing present without skipping, got %d present and %+v", report.Present, report.Volumes)
+	}
+
+	// An absurd margin cannot be satisfied.
+	if report := Preflight([]*fileops.FileMapping{mapping}, SpaceMargin{Bytes: 1 << 62}, false); report.OK() {
+		t.Fatal("expected insufficient space")
+	}
+
+	// A destination below a regular file cannot be created.
+	blocked := *mapping
+	writeTestFile(t, dir, "file", []byte("x"))
+	blocked.DestBAM = filepath.Join(dir, "file", "Sample_A", "A.bam")
+	blocked.DestPBI = blocked.DestBAM + ".pbi"
+	if report := Preflight([]*fileops.FileMapping{&blocked}, SpaceMargin{}, false); report.OK() {
+		t.Fatal("expected a problem for a destination below a regular file")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	dir := t.TempDir()
+	var mappings []*fileops.FileMapping
+	for _, name := range []string{"A", "B"} {
+		mappings = append(mappings, &fileops.FileMapping{
+			SourceBAM: writeTestFile(t, dir, "src/"+name+".bam", []byte("bam-"+name)),
+			SourcePBI: writeTestFile(t, dir, "src/"+name+".bam.pbi", []byte("pbi-"+name)),
+			DestBAM:   filepath.Join(dir, "out", name+".bam"),
+			DestPBI:   filepath.Join(dir, "out", name+".bam.pbi"),
+			BioSample: name,
+		})
+	}
+	manifest, err := LoadManifest(ManifestPath(DefaultStateDir(filepath.Join(dir, "out"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{Hashes: []HashAlgorithm{HashMD5}}), false, false)
+	fc.Manifest = manifest
+	if _, err := fc.CopyAllFileMappings(context.Background(), mappings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt A's BAM without changing its size and remove B's PBI.
+	writeTestFile(t, dir, "out/A.bam", []byte("bam-X"))
+	os.Remove(mappings[1].DestPBI)
+
+	v := &Verifier{Jobs: 2, Manifest: manifest}
+	checks, err := v.VerifyMappings(context.Background(), mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CheckStatus{CheckChecksumMismatch, CheckOK, CheckOK, CheckMissing}
+	for i, c := range checks {
+		if c.Status != want[i] {
+			t.Fatalf("%s: expected %s, got %s (%s)", c.Dest, want[i], c.Status, c.Detail)
+		}
+	}
+
+	// Without the sources, the manifest is the reference.
+	os.RemoveAll(filepath.Join(dir, "src"))
+	checks, err = v.VerifyManifest(context.Background(), manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]CheckStatus)
+	for _, c := range checks {
+		if c.Reference != ReferenceManifest && c.Status != CheckMissing {
+			t.Fatalf("%s: expected manifest reference, got %q", c.Dest, c.Reference)
+		}
+		statuses[filepath.Base(c.Dest)] = c.Status
+	}
+	if statuses["A.bam"] != CheckChecksumMismatch || statuses["B.bam"] != CheckOK || statuses["B.bam.pbi"] != CheckMissing {
+		t.Fatalf("unexpected manifest statuses: %v", statuses)
+	}
+}
+
+func TestWriteChecksumFiles(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var mappings []*fileops.FileMapping
+	for _, name := range []string{"A", "B"} {
+		mappings = append(mappings, &fileops.FileMapping{
+			SourceBAM: writeTestFile(t, dir, "src/"+name+".bam", []byte("bam-"+name)),
+			SourcePBI: writeTestFile(t, dir, "src/"+name+".bam.pbi", []byte("pbi-"+name)),
+			DestBAM:   filepath.Join(out, "Sample_"+name, name+".bam"),
+			DestPBI:   filepath.Join(out, "Sample_"+name, name+".bam.pbi"),
+			BioSample: name,
+		})
+	}
+	// A file from an earlier delivery stays listed; a deleted one is dropped.
+	writeTestFile(t, out, "Sample_C/C.bam", []byte("bam-C"))
+	writeTestFile(t, out, "MD5SUMS", []byte(md5hex("bam-C")+"  Sample_C/C.bam\n"+md5hex("gone")+"  Sample_D/D.bam\n"))
+
+	fc := NewFileCopier(NewNativeCopier(CopierOptions{Hashes: []HashAlgorithm{HashMD5}}), false, false)
+	result, err := fc.CopyAllFileMappings(context.Background(), mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := WriteChecksumFiles(out, result, []HashAlgorithm{HashMD5, HashSHA256}, nil)
+	if err != nil {
+		t.Fatalf("WriteChecksumFiles: %v", err)
+	}
+	if len(written) != 6 {
+		t.Fatalf("expected 6 checksum files, got %v", written)
+	}
+
+	sample, err := os.ReadFile(filepath.Join(out, "Sample_A", "MD5SUMS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := md5hex("bam-A") + "  A.bam\n" + md5hex("pbi-A") + "  A.bam.pbi\n"
+	if string(sample) != want {
+		t.Fatalf("unexpected sample MD5SUMS:\n%s\nwant:\n%s", sample, want)
+	}
+
+	delivery, err := os.ReadFile(filepath.Join(out, "MD5SUMS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = md5hex("bam-A") + "  Sample_A/A.bam\n" + md5hex("pbi-A") + "  Sample_A/A.bam.pbi\n" +
+		md5hex("bam-B") + "  Sample_B/B.bam\n" + md5hex("pbi-B") + "  Sample_B/B.bam.pbi\n" +
+		md5hex("bam-C") + "  Sample_C/C.bam\n"
+	if string(delivery) != want {
+		t.Fatalf("unexpected delivery MD5SUMS:\n%s\nwant:\n%s", delivery, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "SHA256SUMS")); err != nil {
+		t.Fatalf("expected SHA256SUMS: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package copyfiles
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgorithm names a checksum algorithm supported by the copiers.
+type HashAlgorithm string
+
+const (
+	// HashMD5 is the MD5 digest (md5sum compatible).
+	HashMD5 HashAlgorithm = "md5"
+	// HashSHA256 is the SHA-256 digest (sha256sum compatible).
+	HashSHA256 HashAlgorithm = "sha256"
+	// HashXXH64 is the 64-bit xxHash digest, much cheaper to compute than MD5/SHA-256.
+	HashXXH64 HashAlgorithm = "xxhash"
+)
+
+// Checksums maps hash algorithms to hex-encoded digests.
+type Checksums map[HashAlgorithm]string
+
+// newHash returns a fresh hash.Hash for algo.
+func newHash(algo HashAlgorithm) (hash.Hash, error) {
+	switch algo {
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashXXH64:
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q (want md5, sha256 or xxhash)", algo)
+	}
+}
+
+// ParseHashAlgorithms parses a comma-separated list of algorithm names.
+func ParseHashAlgorithms(s string) ([]HashAlgorithm, error) {
+	var algos []HashAlgorithm
+	seen := make(map[HashAlgorithm]bool)
+	for _, part := range strings.Split(s, ",") {
+		algo := HashAlgorithm(strings.ToLower(strings.TrimSpace(part)))
+		if algo == "" || seen[algo] {
+			continue
+		}
+		if _, err := newHash(algo); err != nil {
+			return nil, err
+		}
+		seen[algo] = true
+		algos = append(algos, algo)
+	}
+	return algos, nil
+}
+
+// multiHasher computes several digests over one stream.
+type multiHasher struct {
+	algos  []HashAlgorithm
+	hashes []hash.Hash
+	writer io.Writer
+}
+
+// newMultiHasher returns a writer that feeds every byte to each algorithm.
+func newMultiHasher(algos []HashAlgorithm) (*multiHasher, error) {
+	mh := &multiHasher{algos: algos}
+	writers := make([]io.Writer, 0, len(algos))
+	for _, algo := range algos {
+		h, err := newHash(algo)
+		if err != nil {
+			return nil, err
+		}
+		mh.hashes = append(mh.hashes, h)
+		writers = append(writers, h)
+	}
+	mh.writer = io.MultiWriter(writers...)
+	return mh, nil
+}
+
+func (mh *multiHasher) Write(p []byte) (int, error) { return mh.writer.Write(p) }
+
+// Sums returns the hex digests computed so far.
+func (mh *multiHasher) Sums() Checksums {
+	sums := make(Checksums, len(mh.algos))
+	for i, algo := range mh.algos {
+		sums[algo] = hex.EncodeToString(mh.hashes[i].Sum(nil))
+	}
+	return sums
+}
+
+// HashFile computes the requested digests of a file in one pass.
+func HashFile(path string, algos []HashAlgorithm) (Checksums, int64, error) {
+	mh, err := newMultiHasher(algos)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(mh, f)
+	if err != nil {
+		return nil, n, err
+	}
+	return mh.Sums(), n, nil
+}
@@ -0,0 +1,135 @@
+package copyfiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// NativeCopier is a pure-Go streaming copier that computes checksums while
+// copying and verifies the destination by re-reading it.
+type NativeCopier struct {
+	opts CopierOptions
+}
+
+// NewNativeCopier creates a NativeCopier.
+func NewNativeCopier(opts CopierOptions) *NativeCopier {
+	if len(opts.Hashes) == 0 {
+		opts.Hashes = []HashAlgorithm{HashMD5}
+	}
+	return &NativeCopier{opts: opts}
+}
+
+// Name implements Copier.
+func (nc *NativeCopier) Name() string { return BackendNative }
+
+// Available implements Copier; the native copier needs nothing external.
+func (nc *NativeCopier) Available() error { return nil }
+
+// copyBufferSize is large enough to keep network filesystems streaming.
+const copyBufferSize = 4 << 20
+
+// CopyFile implements Copier.
+func (nc *NativeCopier) CopyFile(ctx context.Context, src, dest string) (*FileResult, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, sourceError(err)
+	}
+	defer in.Close()
+
+	srcInfo, err := in.Stat()
+	if err != nil {
+		return nil, sourceError(err)
+	}
+
+	hasher, err := newMultiHasher(nc.opts.Hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	buf := make([]byte, copyBufferSize)
+	n, err := io.CopyBuffer(io.MultiWriter(out, hasher), throttle(progressReader(ctx, contextReader(ctx, in)), nc.opts.Limiter), buf)
+	if err != nil {
+		out.Close()
+		return nil, fmt.Errorf("copy error: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("sync error: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("close error: %w", err)
+	}
+
+	if n != srcInfo.Size() {
+		return nil, fmt.Errorf("%w: size mismatch: source=%d bytes, copied=%d bytes", ErrVerification, srcInfo.Size(), n)
+	}
+
+	result := &FileResult{Source: src, Dest: dest, Bytes: n, Checksums: hasher.Sums()}
+
+	if nc.opts.Verify {
+		algo := nc.opts.Hashes[0]
+		destSums, destSize, err := HashFile(dest, []HashAlgorithm{algo})
+		if err != nil {
+			return nil, fmt.Errorf("destination verification failed: %w", err)
+		}
+		if destSize != n {
+			return nil, fmt.Errorf("%w: size mismatch: source=%d bytes, destination=%d bytes", ErrVerification, n, destSize)
+		}
+		if destSums[algo] != result.Checksums[algo] {
+			return nil, fmt.Errorf("%w: %s mismatch: source=%s, destination=%s", ErrVerification, algo, result.Checksums[algo], destSums[algo])
+		}
+	}
+
+	return result, nil
+}
+
+// ctxReader fails reads once its context is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextReader wraps r so that copying from it stops when ctx is cancelled.
+func contextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+// countingReader reports the bytes read so far after every read.
+type countingReader struct {
+	r      io.Reader
+	n      int64
+	report func(bytes int64)
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.n += int64(n)
+		cr.report(cr.n)
+	}
+	return n, err
+}
+
+// progressReader wraps r so that the bytes read are reported to the progress
+// callback of ctx, if any.
+func progressReader(ctx context.Context, r io.Reader) io.Reader {
+	report := progressFunc(ctx)
+	if report == nil {
+		return r
+	}
+	return &countingReader{r: r, report: report}
+}
@@ -0,0 +1,57 @@
+package copyfiles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+// PartialSuffix marks a file that is still being copied. Partial files are
+// hidden (leading dot) so watchers matching the final name never see them.
+const PartialSuffix = ".partial"
+
+// PartialPath returns the temporary name dest is written to before it is renamed into place.
+func PartialPath(dest string) string {
+	return filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+PartialSuffix)
+}
+
+// isPartialName reports whether a file name was produced by PartialPath.
+func isPartialName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, PartialSuffix) && len(name) > 1+len(PartialSuffix)
+}
+
+// CleanPartials removes partial files left behind by an interrupted session
+// in the destination directories of mappings and returns the removed paths.
+func CleanPartials(mappings []*fileops.FileMapping) ([]string, error) {
+	seen := make(map[string]bool)
+	var removed []string
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			dir := filepath.Dir(f.Dest)
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			entries, err := os.ReadDir(dir)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			for _, e := range entries {
+				if e.IsDir() || !isPartialName(e.Name()) {
+					continue
+				}
+				path := filepath.Join(dir, e.Name())
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return removed, err
+				}
+				removed = append(removed, path)
+			}
+		}
+	}
+	return removed, nil
+}
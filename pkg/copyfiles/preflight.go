@@ -0,0 +1,180 @@
+package copyfiles
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+)
+
+// errSpaceUnknown is returned by filesystemSpace where free space cannot be determined.
+var errSpaceUnknown = errors.New("free space cannot be determined on this platform")
+
+// SpaceMargin is the free space to leave on a destination filesystem after
+// copying: a fixed number of bytes plus a percentage of the bytes to be written.
+type SpaceMargin struct {
+	Bytes   int64
+	Percent float64
+}
+
+// ParseSpaceMargin parses a margin such as "5%", "100G" or "0".
+func ParseSpaceMargin(s string) (SpaceMargin, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || v < 0 {
+			return SpaceMargin{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return SpaceMargin{Percent: v}, nil
+	}
+	n, err := ParseByteSize(s)
+	if err != nil {
+		return SpaceMargin{}, err
+	}
+	return SpaceMargin{Bytes: n}, nil
+}
+
+// For returns the margin in bytes when needed bytes are written.
+func (m SpaceMargin) For(needed int64) int64 {
+	return m.Bytes + int64(float64(needed)*m.Percent/100)
+}
+
+// VolumeSpace is the space check of one destination filesystem.
+type VolumeSpace struct {
+	Path   string // Existing directory representing the filesystem
+	Needed int64  // Bytes still to be written to it
+	Margin int64  // Bytes to leave free
+	Free   int64  // Bytes available; -1 if unknown
+}
+
+// OK reports whether the filesystem has room for the copy and the margin.
+func (v VolumeSpace) OK() bool { return v.Free < 0 || v.Free >= v.Needed+v.Margin }
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Total    int64 // Bytes of all source files
+	Present  int64 // Bytes that will be skipped as already present at their destination
+	Volumes  []VolumeSpace
+	Problems []error // Space and permission problems; empty if the copy can start
+}
+
+// OK reports whether all checks passed.
+func (r *PreflightReport) OK() bool { return len(r.Problems) == 0 }
+
+// Preflight checks that every destination filesystem has room for the files
+// still to be copied plus margin, and that every destination directory exists
+// and is writable or can be created. It creates no directories. Destinations
+// with the source's size count as present only with skipExisting (resume mode);
+// otherwise they are copied again and need their full size until replaced.
+func Preflight(mappings []*fileops.FileMapping, margin SpaceMargin, skipExisting bool) *PreflightReport {
+	report := &PreflightReport{}
+	volumes := make(map[uint64]*VolumeSpace)
+	var volumeOrder []uint64
+	checkedDirs := make(map[string]bool)
+
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			src, dest := f.Source, f.Dest
+			srcInfo, err := os.Stat(src)
+			if err != nil {
+				continue // Missing sources are reported by identification
+			}
+			report.Total += srcInfo.Size()
+			needed := srcInfo.Size()
+			if destInfo, err := os.Stat(dest); skipExisting && err == nil && destInfo.Size() == srcInfo.Size() {
+				report.Present += needed
+				needed = 0
+			}
+
+			dir := filepath.Dir(dest)
+			existing, err := existingAncestor(dir)
+			if err != nil {
+				report.Problems = append(report.Problems, fmt.Errorf("%s: %w", dir, err))
+				continue
+			}
+			if !checkedDirs[existing] {
+				checkedDirs[existing] = true
+				if err := checkWritable(existing); err != nil {
+					if existing == dir {
+						err = fmt.Errorf("destination directory %s is not writable: %w", dir, err)
+					} else {
+						err = fmt.Errorf("cannot create %s: %s is not writable: %w", dir, existing, err)
+					}
+					report.Problems = append(report.Problems, err)
+				}
+			}
+
+			dev, free, err := filesystemSpace(existing)
+			if err != nil && !errors.Is(err, errSpaceUnknown) {
+				report.Problems = append(report.Problems, fmt.Errorf("checking free space of %s: %w", existing, err))
+				continue
+			}
+			if errors.Is(err, errSpaceUnknown) {
+				free = -1
+			}
+			vol, ok := volumes[dev]
+			if !ok {
+				vol = &VolumeSpace{Path: existing, Free: free}
+				volumes[dev] = vol
+				volumeOrder = append(volumeOrder, dev)
+			}
+			vol.Needed += needed
+		}
+	}
+
+	for _, dev := range volumeOrder {
+		vol := volumes[dev]
+		vol.Margin = margin.For(vol.Needed)
+		if vol.Needed == 0 {
+			vol.Margin = 0
+		}
+		report.Volumes = append(report.Volumes, *vol)
+		if !vol.OK() {
+			report.Problems = append(report.Problems, fmt.Errorf(
+				"not enough free space on %s: %s needed plus %s margin, %s available",
+				vol.Path, ui.FormatBytes(vol.Needed), ui.FormatBytes(vol.Margin), ui.FormatBytes(vol.Free)))
+		}
+	}
+	sort.SliceStable(report.Volumes, func(i, j int) bool { return report.Volumes[i].Path < report.Volumes[j].Path })
+	return report
+}
+
+// existingAncestor returns dir or its nearest existing ancestor, which must be a directory.
+func existingAncestor(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s exists and is not a directory", dir)
+			}
+			return dir, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		dir = parent
+	}
+}
+
+// checkWritable creates and removes a temporary file in dir. This also catches
+// read-only mounts, which permission bits do not reveal.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".revio-copy-preflight-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
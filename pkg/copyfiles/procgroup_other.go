@@ -0,0 +1,8 @@
+//go:build !unix
+
+package copyfiles
+
+import "os/exec"
+
+// detachProcessGroup is a no-op where process groups are not supported.
+func detachProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,120 @@
+package copyfiles
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by all copy workers to cap the total
+// throughput in bytes per second. A nil *RateLimiter does not limit.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter for bytesPerSecond, or nil when bytesPerSecond <= 0.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	rate := float64(bytesPerSecond)
+	return &RateLimiter{rate: rate, burst: rate / 4, tokens: rate / 4, last: time.Now()}
+}
+
+// Rate returns the configured limit in bytes per second (0 when unlimited).
+func (l *RateLimiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	return int64(l.rate)
+}
+
+// WaitN blocks until n bytes may be transferred.
+func (l *RateLimiter) WaitN(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// limitedReader throttles reads through a shared RateLimiter.
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+// maxLimitedRead keeps individual waits short so workers share bandwidth fairly.
+const maxLimitedRead = 256 << 10
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > maxLimitedRead {
+		p = p[:maxLimitedRead]
+	}
+	n, err := lr.r.Read(p)
+	lr.limiter.WaitN(n)
+	return n, err
+}
+
+// throttle wraps r with limiter; a nil limiter returns r unchanged.
+func throttle(r io.Reader, limiter *RateLimiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{r: r, limiter: limiter}
+}
+
+// ParseByteSize parses sizes such as 500, 64k, 100M or 1.5G (binary units) into bytes.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	multiplier := float64(1)
+	number := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "IB"), "B")
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if last := number[len(number)-1]; last < '0' || last > '9' {
+		number = number[:len(number)-1]
+		switch last {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		default:
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(v * multiplier), nil
+}
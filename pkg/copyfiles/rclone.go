@@ -0,0 +1,136 @@
+package copyfiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/logging"
+)
+
+// RcloneCopier copies files by shelling out to `rclone copyto --checksum`.
+type RcloneCopier struct {
+	opts    CopierOptions
+	workers int // Copies running at the same time; opts.Jobs until set by FileCopier
+}
+
+// NewRcloneCopier creates an RcloneCopier.
+func NewRcloneCopier(opts CopierOptions) *RcloneCopier {
+	return &RcloneCopier{opts: opts, workers: opts.Jobs}
+}
+
+// setWorkers implements workerAware.
+func (rc *RcloneCopier) setWorkers(n int) { rc.workers = n }
+
+// Name implements Copier.
+func (rc *RcloneCopier) Name() string { return BackendRclone }
+
+// Available checks if rclone is available in the system path.
+func (rc *RcloneCopier) Available() error { return probeRclone() }
+
+// probeRclone runs `rclone version` once per process; backends created for
+// later runs reuse the result.
+var probeRclone = sync.OnceValue(func() error {
+	cmd := exec.Command("rclone", "version")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rclone not found or not executable: %w", err)
+	}
+	versionLine := strings.Split(string(output), "\n")[0]
+	logging.Debugf("rclone available: %s", versionLine)
+	return nil
+})
+
+// args returns the rclone arguments for copying src to dest.
+func (rc *RcloneCopier) args(ctx context.Context, src, dest string) []string {
+	args := []string{
+		"copyto",
+		"--checksum", // Verify checksums for data integrity
+	}
+	if !rc.quiet(ctx) {
+		args = append(args, "--progress")
+	}
+	if limit := rc.opts.Limiter.Rate(); limit > 0 {
+		// Each rclone process limits itself, so split the global limit evenly
+		// between the copies that run at the same time.
+		perJob := limit / int64(max(rc.workers, 1))
+		args = append(args, "--bwlimit", fmt.Sprintf("%dk", max(perJob/1024, 1)))
+	}
+	return append(args, src, dest)
+}
+
+// quiet reports whether rclone's output must be kept off the terminal: it is
+// unreadable with several concurrent copies and would break a progress bar.
+func (rc *RcloneCopier) quiet(ctx context.Context) bool {
+	return rc.opts.Jobs > 1 || progressFunc(ctx) != nil
+}
+
+// CopyFile uses rclone to copy a file with checksum verification.
+func (rc *RcloneCopier) CopyFile(ctx context.Context, src, dest string) (*FileResult, error) {
+	// Check if source file exists
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return nil, sourceError(err)
+	}
+
+	args := rc.args(ctx, src, dest)
+	quiet := rc.quiet(ctx)
+	if rc.opts.Verbose && progressFunc(ctx) == nil {
+		fmt.Printf("  Command: rclone %s\n", strings.Join(args, " "))
+	}
+
+	// Execute rclone command. It runs in its own process group so that a
+	// terminal Ctrl-C reaches only us; cancelling ctx asks rclone to stop.
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = rcloneStopTimeout
+	detachProcessGroup(cmd)
+
+	if !quiet {
+		// Show output for progress monitoring, keeping stderr to classify failures
+		var stderr bytes.Buffer
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		if err := cmd.Run(); err != nil {
+			return nil, rcloneError(ctx, err, stderr.String(), false)
+		}
+	} else if output, err := cmd.CombinedOutput(); err != nil {
+		// Surface rclone's output only on failure
+		return nil, rcloneError(ctx, err, string(output), true)
+	}
+
+	// Verify destination file exists and has correct size
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return nil, fmt.Errorf("destination verification failed: %w", err)
+	}
+	if destInfo.Size() != srcInfo.Size() {
+		return nil, fmt.Errorf("%w: size mismatch: source=%d bytes, destination=%d bytes",
+			ErrVerification, srcInfo.Size(), destInfo.Size())
+	}
+
+	return &FileResult{Source: src, Dest: dest, Bytes: destInfo.Size()}, nil
+}
+
+// rcloneStopTimeout is how long rclone may take to exit after being interrupted.
+const rcloneStopTimeout = 10 * time.Second
+
+// rcloneError wraps a failed rclone invocation, classifying checksum failures as ErrVerification.
+func rcloneError(ctx context.Context, err error, output string, includeOutput bool) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("rclone stopped: %w", ctx.Err())
+	}
+	if includeOutput {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	if strings.Contains(output, "corrupted on transfer") {
+		return fmt.Errorf("rclone error: %w: %w", ErrVerification, err)
+	}
+	return fmt.Errorf("rclone error: %w", err)
+}
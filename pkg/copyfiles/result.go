@@ -0,0 +1,93 @@
+package copyfiles
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+var (
+	// ErrSourceMissing indicates that a source file did not exist when it was copied.
+	ErrSourceMissing = errors.New("source file missing")
+	// ErrVerification indicates that a copied file did not match its source.
+	ErrVerification = errors.New("verification failed")
+	// ErrInterrupted indicates that a mapping was aborted or never started because the session was cancelled.
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// sourceError wraps a failed stat of a source file, classifying missing files as ErrSourceMissing.
+func sourceError(err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %w", ErrSourceMissing, err)
+	}
+	return fmt.Errorf("source file error: %w", err)
+}
+
+// MappingResult is the outcome of copying one mapping (BAM + PBI).
+type MappingResult struct {
+	Mapping      *fileops.FileMapping
+	Files        []*FileResult // Files delivered, copied or already present; empty in dry-run mode
+	Bytes        int64         // Bytes transferred
+	SkippedBytes int64         // Bytes already present and verified
+	Err          error         // Nil if both files were delivered
+	Started      bool          // False if the session was cancelled before the mapping was scheduled
+}
+
+// OK reports whether the mapping was delivered completely.
+func (r *MappingResult) OK() bool { return r.Err == nil }
+
+// MappingError is a copy failure of one mapping.
+type MappingError struct {
+	Mapping *fileops.FileMapping
+	Err     error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("%s: %v", describeMapping(e.Mapping), e.Err)
+}
+
+func (e *MappingError) Unwrap() error { return e.Err }
+
+// CopyResult is the outcome of CopyAllFileMappings.
+type CopyResult struct {
+	Mappings     []*MappingResult // In the order the mappings were given
+	Copied       int              // Files copied
+	Skipped      int              // Files already present and verified
+	Failed       int              // Files that failed
+	Bytes        int64            // Bytes transferred
+	SkippedBytes int64            // Bytes already present and verified
+	Interrupted  bool             // The session was cancelled while copying
+}
+
+// Succeeded returns the number of mappings delivered completely.
+func (r *CopyResult) Succeeded() int {
+	n := 0
+	for _, m := range r.Mappings {
+		if m.OK() {
+			n++
+		}
+	}
+	return n
+}
+
+// Failures returns the mappings that were not delivered completely.
+func (r *CopyResult) Failures() []*MappingResult {
+	var failed []*MappingResult
+	for _, m := range r.Mappings {
+		if !m.OK() {
+			failed = append(failed, m)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of all failed mappings as *MappingError values; nil if all succeeded.
+func (r *CopyResult) Err() error {
+	var errs []error
+	for _, m := range r.Failures() {
+		errs = append(errs, &MappingError{Mapping: m.Mapping, Err: m.Err})
+	}
+	return errors.Join(errs...)
+}
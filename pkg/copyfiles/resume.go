@@ -0,0 +1,169 @@
+package copyfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ManifestEntry records a destination file that was copied and verified.
+type ManifestEntry struct {
+	Source        string    `json:"source"`
+	SourceSize    int64     `json:"source_size,omitempty"`
+	SourceModTime time.Time `json:"source_mod_time"` // Source modification time when it was copied
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mod_time"` // Destination modification time after the copy
+	Checksums     Checksums `json:"checksums,omitempty"`
+	CompletedAt   time.Time `json:"completed_at"`
+}
+
+// Unchanged reports whether the entry still describes a copy of src in dest:
+// the source path, size and modification time and the destination size and
+// modification time are those recorded. Entries without source details never match.
+func (e ManifestEntry) Unchanged(src string, srcInfo, destInfo os.FileInfo) bool {
+	return e.Source == src && !e.SourceModTime.IsZero() &&
+		e.SourceSize == srcInfo.Size() && e.SourceModTime.Equal(srcInfo.ModTime()) &&
+		e.Size == destInfo.Size() && e.ModTime.Equal(destInfo.ModTime())
+}
+
+// Manifest is the persistent record of verified destination files, keyed by
+// destination path. It lets a resumed session skip files without re-reading them.
+type Manifest struct {
+	path    string
+	mu      sync.Mutex
+	Entries map[string]ManifestEntry `json:"entries"`
+}
+
+// LoadManifest reads the manifest at path; a missing file yields an empty manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Entries: make(map[string]ManifestEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+// Lookup returns the entry recorded for dest.
+func (m *Manifest) Lookup(dest string) (ManifestEntry, bool) {
+	if m == nil {
+		return ManifestEntry{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.Entries[dest]
+	return e, ok
+}
+
+// Add stores an entry in memory without persisting the manifest.
+func (m *Manifest) Add(dest string, entry ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[dest] = entry
+}
+
+// Record stores a verified copy and persists the manifest.
+func (m *Manifest) Record(result *FileResult) error {
+	if m == nil {
+		return nil
+	}
+	info, err := os.Stat(result.Dest)
+	if err != nil {
+		return err
+	}
+	entry := ManifestEntry{
+		Source:      result.Source,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Checksums:   result.Checksums,
+		CompletedAt: time.Now(),
+	}
+	if srcInfo, err := os.Stat(result.Source); err == nil {
+		entry.SourceSize = srcInfo.Size()
+		entry.SourceModTime = srcInfo.ModTime()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[result.Dest] = entry
+	return m.save()
+}
+
+// save writes the manifest atomically; the caller holds m.mu.
+func (m *Manifest) save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// checkExisting reports whether dest already holds a verified copy of src. The
+// manifest is consulted first (source and destination unchanged since the
+// recorded copy); otherwise equal sizes are confirmed by comparing checksums.
+func (fc *FileCopier) checkExisting(src, dest string) (bool, string, error) {
+	destInfo, err := os.Stat(dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, "", fmt.Errorf("source file error: %w", err)
+	}
+	if destInfo.Size() != srcInfo.Size() {
+		return false, "size differs", nil
+	}
+
+	if e, ok := fc.Manifest.Lookup(dest); ok && e.Unchanged(src, srcInfo, destInfo) {
+		return true, "manifest", nil
+	}
+
+	algo := fc.resumeHash()
+	srcSums, _, err := HashFile(src, []HashAlgorithm{algo})
+	if err != nil {
+		return false, "", fmt.Errorf("hashing source: %w", err)
+	}
+	destSums, _, err := HashFile(dest, []HashAlgorithm{algo})
+	if err != nil {
+		return false, "", fmt.Errorf("hashing destination: %w", err)
+	}
+	if srcSums[algo] != destSums[algo] {
+		return false, string(algo) + " differs", nil
+	}
+
+	// Remember the verification so the next resume does not re-read the file.
+	if err := fc.Manifest.Record(&FileResult{Source: src, Dest: dest, Bytes: destInfo.Size(), Checksums: srcSums}); err != nil {
+		return true, string(algo), fmt.Errorf("updating manifest: %w", err)
+	}
+	return true, string(algo), nil
+}
+
+// resumeHash is the algorithm used to compare existing destinations with their source.
+func (fc *FileCopier) resumeHash() HashAlgorithm {
+	if fc.ResumeHash == "" {
+		return HashMD5
+	}
+	return fc.ResumeHash
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package copyfiles
+
+// filesystemSpace is not implemented on this platform; all paths share one
+// unknown filesystem.
+func filesystemSpace(path string) (uint64, int64, error) {
+	return 0, 0, errSpaceUnknown
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+
+package copyfiles
+
+import (
+	"os"
+	"syscall"
+)
+
+// filesystemSpace returns an identifier of the filesystem holding path and the
+// bytes available to unprivileged users on it.
+func filesystemSpace(path string) (uint64, int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var dev uint64
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		dev = uint64(sys.Dev)
+	}
+	return dev, int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
@@ -0,0 +1,185 @@
+package copyfiles
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/logging"
+)
+
+// SumsFileName returns the checksum file name used for algo, e.g. MD5SUMS.
+func SumsFileName(algo HashAlgorithm) string {
+	switch algo {
+	case HashXXH64:
+		return "XXH64SUMS"
+	default:
+		return strings.ToUpper(string(algo)) + "SUMS"
+	}
+}
+
+// WriteChecksumFiles writes md5sum -c compatible checksum files (one per
+// algorithm) for the mappings delivered in result: one in every destination
+// directory, listing its files, and one in outputDir listing all delivered files
+// by relative path. Entries of existing checksum files are kept as long as their
+// file still exists, so deliveries split over several sessions stay complete.
+//
+// Digests come from the copy results. Only those the backend did not report are
+// taken from manifest or, as a last resort, computed from the destination.
+// It returns the checksum files written.
+func WriteChecksumFiles(outputDir string, result *CopyResult, algos []HashAlgorithm, manifest *Manifest) ([]string, error) {
+	if len(algos) == 0 {
+		return nil, nil
+	}
+	outputDir = filepath.Clean(outputDir)
+	byDir := make(map[string]map[string]Checksums)
+	delivery := make(map[string]Checksums)
+
+	for _, m := range result.Mappings {
+		if !m.OK() {
+			continue
+		}
+		for _, f := range m.Files {
+			sums, err := deliveredChecksums(f, algos, manifest)
+			if err != nil {
+				return nil, err
+			}
+			dir := filepath.Dir(f.Dest)
+			if byDir[dir] == nil {
+				byDir[dir] = make(map[string]Checksums)
+			}
+			byDir[dir][filepath.Base(f.Dest)] = sums
+			if rel, err := filepath.Rel(outputDir, f.Dest); err == nil && !strings.HasPrefix(rel, "..") {
+				delivery[filepath.ToSlash(rel)] = sums
+			}
+		}
+	}
+
+	var written []string
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if dir == outputDir {
+			continue // Covered by the delivery file
+		}
+		paths, err := updateSumsFiles(dir, byDir[dir], algos)
+		written = append(written, paths...)
+		if err != nil {
+			return written, err
+		}
+	}
+	if len(delivery) > 0 {
+		paths, err := updateSumsFiles(outputDir, delivery, algos)
+		written = append(written, paths...)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// deliveredChecksums returns the digests of a delivered file for algos.
+func deliveredChecksums(f *FileResult, algos []HashAlgorithm, manifest *Manifest) (Checksums, error) {
+	sums := make(Checksums, len(algos))
+	var missing []HashAlgorithm
+	entry, inManifest := manifest.Lookup(f.Dest)
+	for _, algo := range algos {
+		if sum := f.Checksums[algo]; sum != "" {
+			sums[algo] = sum
+		} else if sum := entry.Checksums[algo]; inManifest && sum != "" {
+			sums[algo] = sum
+		} else {
+			missing = append(missing, algo)
+		}
+	}
+	if len(missing) > 0 {
+		logging.Debugf("computing %v of %s: not reported by the copy backend", missing, f.Dest)
+		computed, _, err := HashFile(f.Dest, missing)
+		if err != nil {
+			return nil, fmt.Errorf("checksumming %s: %w", f.Dest, err)
+		}
+		for algo, sum := range computed {
+			sums[algo] = sum
+		}
+	}
+	return sums, nil
+}
+
+// updateSumsFiles merges entries (file names relative to dir) into the checksum file of each algorithm in dir.
+func updateSumsFiles(dir string, entries map[string]Checksums, algos []HashAlgorithm) ([]string, error) {
+	var written []string
+	for _, algo := range algos {
+		path := filepath.Join(dir, SumsFileName(algo))
+		lines, err := readSumsFile(path)
+		if err != nil {
+			return written, err
+		}
+		for name := range lines {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				delete(lines, name)
+			}
+		}
+		for name, sums := range entries {
+			lines[name] = sums[algo]
+		}
+		if err := writeSumsFile(path, lines); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// readSumsFile parses "<digest>  <name>" lines; a missing file yields no entries.
+func readSumsFile(path string) (map[string]string, error) {
+	lines := make(map[string]string)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return lines, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		// Text mode uses two spaces, binary mode " *"
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		if name != "" {
+			lines[name] = sum
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// writeSumsFile atomically writes lines sorted by name.
+func writeSumsFile(path string, lines map[string]string) error {
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", lines[name], name)
+	}
+	partial := PartialPath(path)
+	if err := os.WriteFile(partial, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(partial, path); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package copyfiles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+// CheckStatus is the outcome of verifying one delivered file.
+type CheckStatus string
+
+const (
+	// CheckOK means the destination matches its reference.
+	CheckOK CheckStatus = "ok"
+	// CheckMissing means the destination file does not exist.
+	CheckMissing CheckStatus = "missing"
+	// CheckSizeMismatch means the destination size differs from the reference.
+	CheckSizeMismatch CheckStatus = "size-mismatch"
+	// CheckChecksumMismatch means the destination checksum differs from the reference.
+	CheckChecksumMismatch CheckStatus = "checksum-mismatch"
+	// CheckNoReference means neither the source nor a manifest entry was available.
+	CheckNoReference CheckStatus = "no-reference"
+	// CheckError means the check itself failed, e.g. a file could not be read.
+	CheckError CheckStatus = "error"
+)
+
+// Reference sources a destination is compared against.
+const (
+	ReferenceSource   = "source"
+	ReferenceManifest = "manifest"
+)
+
+// FileCheck is the verification result of one destination file.
+type FileCheck struct {
+	BioSample    string        `json:"biosample,omitempty"`
+	Kind         string        `json:"kind,omitempty"` // BAM or PBI
+	Dest         string        `json:"dest"`
+	Source       string        `json:"source,omitempty"`
+	Reference    string        `json:"reference,omitempty"` // ReferenceSource or ReferenceManifest
+	Status       CheckStatus   `json:"status"`
+	Size         int64         `json:"size"`
+	ExpectedSize int64         `json:"expected_size"`
+	Algorithm    HashAlgorithm `json:"algorithm,omitempty"`
+	Expected     string        `json:"expected,omitempty"`
+	Actual       string        `json:"actual,omitempty"`
+	Detail       string        `json:"detail,omitempty"`
+
+	entry     *ManifestEntry
+	useSource bool // Compare against the source when it exists
+}
+
+// OK reports whether the file passed verification.
+func (c *FileCheck) OK() bool { return c.Status == CheckOK }
+
+// Verifier re-checks delivered files against their sources or a manifest.
+type Verifier struct {
+	Hash     HashAlgorithm // Checksum compared against the source; default md5
+	Jobs     int           // Files checked concurrently; values < 1 mean 1
+	Manifest *Manifest     // Reference for files whose source is gone; may be nil
+}
+
+// VerifyMappings checks the destinations of mappings against their sources.
+// Files whose source no longer exists are checked against the manifest entry.
+func (v *Verifier) VerifyMappings(ctx context.Context, mappings []*fileops.FileMapping) ([]*FileCheck, error) {
+	var checks []*FileCheck
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			c := &FileCheck{BioSample: m.BioSample, Kind: f.Kind, Source: f.Source, Dest: f.Dest, useSource: true}
+			if entry, ok := v.Manifest.Lookup(c.Dest); ok {
+				c.entry = &entry
+			}
+			checks = append(checks, c)
+		}
+	}
+	return checks, v.run(ctx, checks)
+}
+
+// VerifyManifest checks every destination recorded in manifest against its
+// recorded size and checksum; the sources are not needed.
+func (v *Verifier) VerifyManifest(ctx context.Context, manifest *Manifest) ([]*FileCheck, error) {
+	manifest.mu.Lock()
+	dests := make([]string, 0, len(manifest.Entries))
+	for dest := range manifest.Entries {
+		dests = append(dests, dest)
+	}
+	manifest.mu.Unlock()
+	sort.Strings(dests)
+
+	checks := make([]*FileCheck, 0, len(dests))
+	for _, dest := range dests {
+		entry, _ := manifest.Lookup(dest)
+		checks = append(checks, &FileCheck{Dest: dest, Source: entry.Source, entry: &entry})
+	}
+	return checks, v.run(ctx, checks)
+}
+
+// run executes checks with up to Jobs workers and stops scheduling when ctx is cancelled.
+func (v *Verifier) run(ctx context.Context, checks []*FileCheck) error {
+	tasks := make(chan *FileCheck)
+	var wg sync.WaitGroup
+	for w := 0; w < max(v.Jobs, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range tasks {
+				v.check(c)
+			}
+		}()
+	}
+	for _, c := range checks {
+		if ctx.Err() != nil {
+			break
+		}
+		tasks <- c
+	}
+	close(tasks)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// check verifies one file, preferring the source over the manifest as reference.
+func (v *Verifier) check(c *FileCheck) {
+	destInfo, err := os.Stat(c.Dest)
+	if os.IsNotExist(err) {
+		c.Status = CheckMissing
+		return
+	}
+	if err != nil {
+		c.Status, c.Detail = CheckError, err.Error()
+		return
+	}
+	c.Size = destInfo.Size()
+
+	var algo HashAlgorithm
+	if srcInfo, err := os.Stat(c.Source); c.useSource && err == nil {
+		c.Reference, c.ExpectedSize = ReferenceSource, srcInfo.Size()
+		algo = v.hash()
+	} else if c.entry != nil {
+		c.Reference, c.ExpectedSize = ReferenceManifest, c.entry.Size
+		algo = v.manifestHash(c.entry.Checksums)
+		if c.useSource {
+			c.Detail = "source not available"
+		}
+	} else {
+		c.Status, c.Detail = CheckNoReference, "source not available and not in manifest"
+		return
+	}
+
+	if c.Size != c.ExpectedSize {
+		c.Status = CheckSizeMismatch
+		return
+	}
+	if algo == "" {
+		c.Status, c.Detail = CheckOK, "size only; no checksum recorded"
+		return
+	}
+
+	c.Algorithm = algo
+	if c.Reference == ReferenceSource {
+		sums, _, err := HashFile(c.Source, []HashAlgorithm{algo})
+		if err != nil {
+			c.Status, c.Detail = CheckError, fmt.Sprintf("reading source: %v", err)
+			return
+		}
+		c.Expected = sums[algo]
+	} else {
+		c.Expected = c.entry.Checksums[algo]
+	}
+	sums, _, err := HashFile(c.Dest, []HashAlgorithm{algo})
+	if err != nil {
+		c.Status, c.Detail = CheckError, fmt.Sprintf("reading destination: %v", err)
+		return
+	}
+	c.Actual = sums[algo]
+	if c.Actual != c.Expected {
+		c.Status = CheckChecksumMismatch
+		return
+	}
+	c.Status = CheckOK
+}
+
+// hash returns the algorithm used against sources.
+func (v *Verifier) hash() HashAlgorithm {
+	if v.Hash == "" {
+		return HashMD5
+	}
+	return v.Hash
+}
+
+// manifestHash picks the configured algorithm if recorded, otherwise any recorded one.
+func (v *Verifier) manifestHash(sums Checksums) HashAlgorithm {
+	if _, ok := sums[v.hash()]; ok {
+		return v.hash()
+	}
+	var algos []string
+	for algo := range sums {
+		algos = append(algos, string(algo))
+	}
+	if len(algos) == 0 {
+		return ""
+	}
+	sort.Strings(algos)
+	return HashAlgorithm(algos[0])
+}
@@ -0,0 +1,84 @@
+package delivery
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+func TestRegistry(t *testing.T) {
+	path := RegistryPath(t.TempDir())
+	r, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := r.Delivered("R1", "/out"); ok {
+		t.Fatal("empty registry reports a delivery")
+	}
+	if err := r.Add(Record{Run: "R1", OutputDir: "/out/", Files: 4}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Another process records a delivery in the meantime
+	other, _ := LoadRegistry(path)
+	if err := other.Add(Record{Run: "R2", OutputDir: "/out"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Add(Record{Run: "R3", OutputDir: "/elsewhere"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reloaded.Deliveries) != 3 {
+		t.Fatalf("expected 3 deliveries got %+v", reloaded.Deliveries)
+	}
+	if rec, ok := reloaded.Delivered("R1", "/out"); !ok || rec.Files != 4 || rec.DeliveredAt.IsZero() {
+		t.Fatalf("unexpected record %+v %v", rec, ok)
+	}
+	if _, ok := reloaded.Delivered("R3", "/out"); ok {
+		t.Fatal("delivery to another output directory must not count")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	run := &metadata.RunInfo{
+		Name:           "r84001_20250922_100000",
+		BioSampleNames: map[string]bool{"LAB1_S1": true, "S2": true},
+		Cells: []*metadata.MetadataInfo{{
+			FilePath: filepath.Join("runs", "r84001_20250922_100000", "1_A01", "metadata", "m.metadata.xml"),
+			Cell:     metadata.CellInfo{InstrumentName: "Revio1", InstrumentID: "84001"},
+		}},
+	}
+	router, err := NewRouter([]Route{
+		{Run: "*_test_*", Skip: true},
+		{BioSample: "LAB2_*", Output: "/lab2"},
+		{Run: "r84001_*", BioSample: "LAB1_*", Instrument: "84001", Output: "/lab1"},
+	}, "/default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out, ok := router.OutputFor(run); !ok || out != "/lab1" {
+		t.Fatalf("expected /lab1 got %q %v", out, ok)
+	}
+
+	run.Name = "r84001_test_1"
+	if _, ok := router.OutputFor(run); ok {
+		t.Fatal("expected skip route to match")
+	}
+
+	run.Name, run.BioSampleNames = "r84002_x", map[string]bool{"S9": true}
+	if out, ok := router.OutputFor(run); !ok || out != "/default" {
+		t.Fatalf("expected default output got %q %v", out, ok)
+	}
+
+	if _, err := NewRouter([]Route{{Run: "["}}, ""); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+	if _, err := NewRouter([]Route{{Run: "*"}}, ""); err == nil {
+		t.Fatal("expected error for route without output")
+	}
+}
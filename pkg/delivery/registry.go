@@ -0,0 +1,106 @@
+// Package delivery records which runs were delivered where and decides where
+// runs are delivered to, so unattended modes (watch, sync) never deliver a run twice.
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record describes one successful delivery of a run.
+type Record struct {
+	Run         string    `json:"run"`
+	OutputDir   string    `json:"output_dir"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Journal     string    `json:"journal,omitempty"` // Journal of the copy session
+	Mappings    int       `json:"mappings"`          // Delivered biosamples and optional files
+	Files       int       `json:"files"`
+	Bytes       int64     `json:"bytes"`
+}
+
+// Registry is the persistent list of deliveries made from a state directory.
+type Registry struct {
+	path       string
+	mu         sync.Mutex
+	Deliveries []Record `json:"deliveries"`
+}
+
+// RegistryPath returns the location of the delivery registry in a state directory.
+func RegistryPath(stateDir string) string {
+	return filepath.Join(stateDir, "deliveries.json")
+}
+
+// LoadRegistry reads the registry at path; a missing file yields an empty registry.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load replaces the in-memory records with the file contents; the caller holds r.mu
+// or has exclusive access.
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.Deliveries = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return fmt.Errorf("reading delivery registry %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// Delivered returns the latest delivery of run into outputDir.
+func (r *Registry) Delivered(run, outputDir string) (Record, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.Deliveries) - 1; i >= 0; i-- {
+		rec := r.Deliveries[i]
+		if rec.Run == run && filepath.Clean(rec.OutputDir) == filepath.Clean(outputDir) {
+			return rec, true
+		}
+	}
+	return Record{}, false
+}
+
+// Add records a delivery and persists the registry. The file is re-read first
+// so deliveries recorded by other processes in the meantime are kept.
+func (r *Registry) Add(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return err
+	}
+	if rec.DeliveredAt.IsZero() {
+		rec.DeliveredAt = time.Now()
+	}
+	r.Deliveries = append(r.Deliveries, rec)
+	return r.save()
+}
+
+// save writes the registry atomically; the caller holds r.mu.
+func (r *Registry) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,110 @@
+package delivery
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+// Route sends matching runs to an output directory. Empty patterns match
+// everything; patterns are shell globs (path.Match) compared case-sensitively.
+type Route struct {
+	Run        string `mapstructure:"run" yaml:"run"`               // Run name
+	BioSample  string `mapstructure:"biosample" yaml:"biosample"`   // Matches if any biosample of the run matches
+	Instrument string `mapstructure:"instrument" yaml:"instrument"` // Instrument name or serial of any cell
+	Output     string `mapstructure:"output" yaml:"output"`         // Output directory for matching runs
+	Skip       bool   `mapstructure:"skip" yaml:"skip"`             // Never deliver matching runs
+}
+
+// Validate checks the route's patterns and that it has a destination.
+func (r Route) Validate() error {
+	for _, pattern := range []string{r.Run, r.BioSample, r.Instrument} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if r.Output == "" && !r.Skip {
+		return fmt.Errorf("route for run %q has no output and is not skip", r.Run)
+	}
+	return nil
+}
+
+// Matches reports whether run satisfies every pattern of the route.
+func (r Route) Matches(run *metadata.RunInfo) bool {
+	if !glob(r.Run, run.Name) {
+		return false
+	}
+	if r.BioSample != "" && !anyMatch(r.BioSample, bioSampleNames(run)) {
+		return false
+	}
+	if r.Instrument != "" && !anyMatch(r.Instrument, instruments(run)) {
+		return false
+	}
+	return true
+}
+
+// Router picks the output directory of a run: the first matching route wins,
+// otherwise runs go to Default.
+type Router struct {
+	Routes  []Route
+	Default string // Output directory for runs no route matches; empty skips them
+}
+
+// NewRouter validates routes and returns a router.
+func NewRouter(routes []Route, defaultOutput string) (*Router, error) {
+	for i, r := range routes {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("route %d: %w", i+1, err)
+		}
+	}
+	return &Router{Routes: routes, Default: defaultOutput}, nil
+}
+
+// OutputFor returns the output directory for run and false when the run is not delivered.
+func (rt *Router) OutputFor(run *metadata.RunInfo) (string, bool) {
+	for _, r := range rt.Routes {
+		if r.Matches(run) {
+			return r.Output, !r.Skip
+		}
+	}
+	return rt.Default, rt.Default != ""
+}
+
+func glob(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func anyMatch(pattern string, names []string) bool {
+	for _, name := range names {
+		if glob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func bioSampleNames(run *metadata.RunInfo) []string {
+	names := make([]string, 0, len(run.BioSampleNames))
+	for name := range run.BioSampleNames {
+		names = append(names, name)
+	}
+	return names
+}
+
+func instruments(run *metadata.RunInfo) []string {
+	var names []string
+	for _, cell := range run.Cells {
+		for _, name := range []string{cell.Cell.InstrumentName, cell.Cell.InstrumentID} {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
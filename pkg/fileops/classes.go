@@ -0,0 +1,274 @@
+package fileops
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+// FileClass names a kind of run artefact that can be delivered.
+type FileClass string
+
+const (
+	// ClassHiFi is the HiFi read BAM + PBI of each biosample; always delivered.
+	ClassHiFi FileClass = "hifi_reads"
+	// ClassFailReads is the failed reads BAM + PBI of each biosample.
+	ClassFailReads FileClass = "fail_reads"
+	// ClassUnassigned is the HiFi reads BAM + PBI without a barcode, per multiplexed cell.
+	ClassUnassigned FileClass = "unassigned"
+	// ClassStatistics is every file of a cell's statistics (reports) directory.
+	ClassStatistics FileClass = "statistics"
+	// ClassDataSet is the consensusreadset XML of each biosample.
+	ClassDataSet FileClass = "consensusreadset"
+	// ClassMetadata is the run metadata XML of each cell.
+	ClassMetadata FileClass = "metadata"
+)
+
+// ExtraClasses lists the optional file classes in report order.
+var ExtraClasses = []FileClass{ClassFailReads, ClassUnassigned, ClassStatistics, ClassDataSet, ClassMetadata}
+
+// Placement of the optional classes:
+//
+//	fail_reads        <sample dir>/fail_reads/<file>
+//	consensusreadset  <sample dir>/<file>
+//	unassigned        <output>/unassigned/<file>
+//	statistics        <output>/statistics/<movie>/<path in statistics dir>
+//	metadata          <output>/metadata/<file>
+//
+// The sample directory is the directory of the biosample's HiFi BAM after
+// collision handling. Source file names contain the movie name, so files of
+// different cells never collide.
+
+// ParseFileClasses parses a comma-separated list of optional file classes;
+// "all" selects every class and "hifi_reads" is accepted but implied.
+func ParseFileClasses(s string) ([]FileClass, error) {
+	selected := make(map[FileClass]bool)
+	for _, part := range strings.Split(s, ",") {
+		name := FileClass(strings.ToLower(strings.TrimSpace(part)))
+		switch {
+		case name == "" || name == ClassHiFi:
+		case name == "all":
+			for _, c := range ExtraClasses {
+				selected[c] = true
+			}
+		case isExtraClass(name):
+			selected[name] = true
+		default:
+			return nil, fmt.Errorf("unknown file class %q (want %s or all)", name, joinClasses(ExtraClasses))
+		}
+	}
+	var classes []FileClass
+	for _, c := range ExtraClasses {
+		if selected[c] {
+			classes = append(classes, c)
+		}
+	}
+	return classes, nil
+}
+
+func isExtraClass(c FileClass) bool {
+	for _, extra := range ExtraClasses {
+		if c == extra {
+			return true
+		}
+	}
+	return false
+}
+
+func joinClasses(classes []FileClass) string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
+
+// FilePair is one source file and its destination.
+type FilePair struct {
+	Kind   string // BAM, PBI or FILE
+	Source string
+	Dest   string
+}
+
+// IsHiFi reports whether m is a HiFi read mapping. Mappings recorded before
+// file classes existed have no class and are HiFi mappings.
+func (m *FileMapping) IsHiFi() bool { return m.Class == "" || m.Class == ClassHiFi }
+
+// Files returns the files of the mapping: the BAM (or the single file of
+// classes without an index) followed by its PBI, if any.
+func (m *FileMapping) Files() []FilePair {
+	kind := "FILE"
+	if strings.HasSuffix(m.SourceBAM, ".bam") {
+		kind = "BAM"
+	}
+	files := []FilePair{{Kind: kind, Source: m.SourceBAM, Dest: m.DestBAM}}
+	if m.SourcePBI != "" {
+		files = append(files, FilePair{Kind: "PBI", Source: m.SourcePBI, Dest: m.DestPBI})
+	}
+	return files
+}
+
+// Label identifies the mapping in progress output and reports. Multi-cell
+// biosamples include the movie; optional classes include the class and file.
+func (m *FileMapping) Label() string {
+	if !m.IsHiFi() {
+		return string(m.Class) + " " + filepath.Base(m.DestBAM)
+	}
+	if m.MultiCell && m.Cell.MovieName != "" {
+		return m.BioSample + " " + m.Cell.MovieName
+	}
+	return m.BioSample
+}
+
+// identifyExtraFiles returns the mappings of the optional classes for the cells
+// of a run. hifi holds the resolved HiFi mappings, which define the sample
+// directories. Files that do not exist are skipped: not every run has every artefact.
+func identifyExtraFiles(cells []*metadata.MetadataInfo, hifi []*FileMapping, opts IdentifyOptions) ([]*FileMapping, error) {
+	var extras []*FileMapping
+	for _, class := range opts.Extra {
+		for _, cell := range cells {
+			cellDir := cell.CellDir()
+			movie := cell.Cell.MovieName
+			var found []*FileMapping
+			var err error
+			switch class {
+			case ClassFailReads, ClassDataSet:
+				found = perSampleFiles(class, cell, cellDir, hifi)
+			case ClassUnassigned:
+				if bam := filepath.Join(cellDir, "hifi_reads", movie+".hifi_reads.unassigned.bam"); movie != "" && exists(bam) {
+					found = append(found, extraMapping(class, cell, bam, filepath.Join(opts.OutputDir, "unassigned")))
+				}
+			case ClassStatistics:
+				found, err = statisticsFiles(cell, filepath.Join(cellDir, "statistics"), opts.OutputDir)
+			case ClassMetadata:
+				found = append(found, extraMapping(class, cell, cell.FilePath, filepath.Join(opts.OutputDir, "metadata")))
+			}
+			if err != nil {
+				return extras, fmt.Errorf("cell %s: %s: %w", cell.Cell, class, err)
+			}
+			if len(found) == 0 {
+				debugf("no %s files found for cell %s", class, cell.Cell)
+			}
+			extras = append(extras, found...)
+		}
+	}
+	return uniqueMappings(extras), nil
+}
+
+// uniqueMappings drops mappings that repeat the source and destination of an
+// earlier one. Collections of one cell share its directory and metadata file,
+// so their extras would otherwise be copied twice to the same destination.
+func uniqueMappings(mappings []*FileMapping) []*FileMapping {
+	seen := make(map[[2]string]bool, len(mappings))
+	unique := mappings[:0]
+	for _, m := range mappings {
+		key := [2]string{m.SourceBAM, m.DestBAM}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, m)
+	}
+	return unique
+}
+
+// perSampleFiles returns the fail_reads BAMs or dataset XMLs of the biosamples of cell.
+func perSampleFiles(class FileClass, cell *metadata.MetadataInfo, cellDir string, hifi []*FileMapping) []*FileMapping {
+	movie := cell.Cell.MovieName
+	if movie == "" {
+		return nil
+	}
+	var found []*FileMapping
+	for _, m := range hifi {
+		if m.Cell.MovieName != movie {
+			continue
+		}
+		var candidates []string
+		var dir, destDir string
+		switch class {
+		case ClassFailReads:
+			dir, destDir = filepath.Join(cellDir, "fail_reads"), filepath.Join(filepath.Dir(m.DestBAM), "fail_reads")
+			candidates = barcodedNames(movie+".fail_reads", m.Barcode, ".bam")
+		case ClassDataSet:
+			dir, destDir = filepath.Join(cellDir, "hifi_reads"), filepath.Dir(m.DestBAM)
+			candidates = barcodedNames(movie+".hifi_reads", m.Barcode, ".consensusreadset.xml")
+		}
+		for _, name := range candidates {
+			if path := filepath.Join(dir, name); exists(path) {
+				extra := extraMapping(class, cell, path, destDir)
+				extra.BioSample, extra.Barcode = m.BioSample, m.Barcode
+				found = append(found, extra)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// barcodedNames returns the candidate file names <prefix>[.<barcode>]<suffix>,
+// trying the <fwd>--<rev> form and, for symmetric pairs, the single barcode.
+func barcodedNames(prefix, barcode, suffix string) []string {
+	if barcode == "" {
+		return []string{prefix + suffix}
+	}
+	var names []string
+	for _, bc := range metadata.BarcodeFileNames(barcode) {
+		names = append(names, prefix+"."+bc+suffix)
+	}
+	return names
+}
+
+// statisticsFiles returns every file below statsDir, placed under statistics/<movie>.
+func statisticsFiles(cell *metadata.MetadataInfo, statsDir, outputDir string) ([]*FileMapping, error) {
+	if !exists(statsDir) {
+		return nil, nil
+	}
+	movie := cell.Cell.MovieName
+	if movie == "" {
+		movie = filepath.Base(filepath.Dir(statsDir))
+	}
+	var found []*FileMapping
+	err := filepath.WalkDir(statsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(statsDir, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(outputDir, "statistics", movie, rel)
+		found = append(found, &FileMapping{
+			SourceBAM: path,
+			DestBAM:   dest,
+			Class:     ClassStatistics,
+			Cell:      cell.Cell,
+		})
+		return nil
+	})
+	sort.Slice(found, func(i, j int) bool { return found[i].SourceBAM < found[j].SourceBAM })
+	return found, err
+}
+
+// extraMapping maps src into destDir under its own name; BAMs bring their PBI if present.
+func extraMapping(class FileClass, cell *metadata.MetadataInfo, src, destDir string) *FileMapping {
+	m := &FileMapping{
+		SourceBAM: src,
+		DestBAM:   filepath.Join(destDir, filepath.Base(src)),
+		Class:     class,
+		Cell:      cell.Cell,
+	}
+	if strings.HasSuffix(src, ".bam") && exists(src+".pbi") {
+		m.SourcePBI, m.DestPBI = src+".pbi", m.DestBAM+".pbi"
+	}
+	return m
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,125 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CollisionPolicy decides what happens when several mappings target the same destination,
+// typically because a biosample was sequenced on more than one SMRT cell.
+type CollisionPolicy string
+
+const (
+	// CollisionSuffix appends the cell (movie) ID to each colliding file name.
+	CollisionSuffix CollisionPolicy = "suffix"
+	// CollisionSubfolder places each colliding cell in its own subfolder of the sample directory.
+	CollisionSubfolder CollisionPolicy = "subfolder"
+	// CollisionFail refuses to plan the copy.
+	CollisionFail CollisionPolicy = "fail"
+)
+
+// ErrDestinationCollision is returned by CollisionFail when destinations collide.
+var ErrDestinationCollision = errors.New("several source files map to the same destination")
+
+// ParseCollisionPolicy validates a policy name.
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	switch p := CollisionPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case CollisionSuffix, CollisionSubfolder, CollisionFail:
+		return p, nil
+	case "":
+		return CollisionSuffix, nil
+	default:
+		return "", fmt.Errorf("invalid collision policy %q (want suffix, subfolder or fail)", s)
+	}
+}
+
+// resolveCollisions finds mappings sharing a destination BAM, marks them as
+// multi-cell and rewrites their destinations according to policy. ext is the
+// extension of the layout's file names, before which suffixes are inserted.
+func resolveCollisions(mappings []*FileMapping, policy CollisionPolicy, ext string) error {
+	groups := make(map[string][]*FileMapping)
+	for _, m := range mappings {
+		groups[m.DestBAM] = append(groups[m.DestBAM], m)
+	}
+
+	dests := make([]string, 0, len(groups))
+	for dest, group := range groups {
+		if len(group) > 1 {
+			dests = append(dests, dest)
+		}
+	}
+	sort.Strings(dests)
+
+	var errs []error
+	for _, dest := range dests {
+		group := groups[dest]
+		for _, m := range group {
+			m.MultiCell = true
+		}
+		if policy == CollisionFail {
+			sources := make([]string, 0, len(group))
+			for _, m := range group {
+				sources = append(sources, m.SourceBAM)
+			}
+			errs = append(errs, fmt.Errorf("%w: %s <- %s", ErrDestinationCollision, dest, strings.Join(sources, ", ")))
+			continue
+		}
+
+		ids := cellIDs(group)
+		for i, m := range group {
+			switch policy {
+			case CollisionSubfolder:
+				m.DestBAM = filepath.Join(filepath.Dir(m.DestBAM), ids[i], filepath.Base(m.DestBAM))
+				m.DestPBI = filepath.Join(filepath.Dir(m.DestPBI), ids[i], filepath.Base(m.DestPBI))
+			default:
+				m.DestBAM = insertSuffix(m.DestBAM, ids[i], ext)
+				m.DestPBI = insertSuffix(m.DestPBI, ids[i], ext)
+			}
+			debugf("destination collision on %s resolved to %s", dest, m.DestBAM)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// cellIDs returns a distinguishing ID per mapping: the movie name, extended with
+// the barcode when the same cell appears twice in the group.
+func cellIDs(group []*FileMapping) []string {
+	ids := make([]string, len(group))
+	count := make(map[string]int)
+	for i, m := range group {
+		ids[i] = m.Cell.MovieName
+		if ids[i] == "" {
+			ids[i] = m.Cell.Position()
+		}
+		if ids[i] == "" {
+			ids[i] = fmt.Sprintf("cell%d", i+1)
+		}
+		count[ids[i]]++
+	}
+	for i, m := range group {
+		if count[ids[i]] > 1 && m.Barcode != "" {
+			ids[i] += "." + m.Barcode
+		}
+	}
+	return ids
+}
+
+// insertSuffix inserts id before the extension of the file name: ext (the
+// layout's fixed file name ending) or else .bam, followed by any .pbi,
+// e.g. HG002.rep1.mod.unmapped.bam -> HG002.rep1.<id>.mod.unmapped.bam.
+func insertSuffix(path, id, ext string) string {
+	dir, base := filepath.Split(path)
+	for _, e := range []string{ext + ".pbi", ext, ".bam.pbi", ".bam"} {
+		if e == ".pbi" || e == "" {
+			continue
+		}
+		if stem, ok := strings.CutSuffix(base, e); ok && stem != "" {
+			return dir + stem + "." + id + e
+		}
+	}
+	return dir + base + "." + id
+}
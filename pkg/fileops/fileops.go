@@ -1,28 +1,56 @@
 package fileops
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/schnurbe/revio-copy/pkg/metadata"
 )
 
 // FileMapping represents mapping between source BAM/PBI files and their destinations.
+// Classes without an index file (XML, reports) use SourceBAM/DestBAM for their
+// single file and leave SourcePBI/DestPBI empty; see Files.
 type FileMapping struct {
 	SourceBAM string
 	SourcePBI string
 	DestBAM   string
 	DestPBI   string
 	BioSample string
+	Barcode   string
+	Cell      metadata.CellInfo
+	MultiCell bool      // The biosample's destination is shared with another cell
+	Class     FileClass // Kind of artefact; empty for HiFi mappings of older journals
 }
 
-// IdentifyHiFiFiles identifies HiFi read BAM and PBI files for a given metadata file
-// Returns the source and destination file paths without copying
-// IdentifyHiFiFiles returns file mappings for a single metadata XML file + its biosamples without copying.
-func IdentifyHiFiFiles(metadataPath string, biosamples []metadata.BioSampleInfo, outputDir string) ([]*FileMapping, error) {
-	debugf("Processing metadata file: %s for biosamples: %v", metadataPath, biosamples)
+// IdentifyOptions controls where identified files are planned to go.
+type IdentifyOptions struct {
+	OutputDir string
+	Layout    *Layout         // Destination layout; nil means DefaultLayout
+	Collision CollisionPolicy // Handling of destinations shared by several cells
+	Extra     []FileClass     // Optional file classes delivered in addition to HiFi reads
+	Filter    *Filter         // Biosamples and cells to identify; nil means all
+}
+
+// layout returns the configured layout or the default one.
+func (o IdentifyOptions) layout() *Layout {
+	if o.Layout == nil {
+		return DefaultLayout()
+	}
+	return o.Layout
+}
+
+// IdentifyHiFiFiles returns file mappings for a single cell (metadata XML file + its biosamples) without copying.
+func IdentifyHiFiFiles(cell *metadata.MetadataInfo, opts IdentifyOptions) ([]*FileMapping, error) {
+	metadataPath := cell.FilePath
+	biosamples := cell.BioSamples
+	if !opts.Filter.MatchCell(cell.Cell) {
+		debugf("Skipping cell %s: not selected by the filter", cell.Cell)
+		return nil, nil
+	}
+	debugf("Processing metadata file: %s (cell %s) for biosamples: %v", metadataPath, cell.Cell, biosamples)
 
 	// Determine source directory - metadata file is in the metadata subdir
 	metadataDir := filepath.Dir(metadataPath)
@@ -42,6 +70,7 @@ func IdentifyHiFiFiles(metadataPath string, biosamples []metadata.BioSampleInfo,
 	}
 
 	var mappings []*FileMapping
+	var errs []error
 
 	multiplexed := false
 	for _, b := range biosamples { // robust detection instead of only first element
@@ -51,103 +80,125 @@ func IdentifyHiFiFiles(metadataPath string, biosamples []metadata.BioSampleInfo,
 		}
 	}
 
+	// Unselected biosamples are not resolved
+	var selected []metadata.BioSampleInfo
+	for _, b := range biosamples {
+		if opts.Filter.MatchSample(b.Name) {
+			selected = append(selected, b)
+		}
+	}
+	if len(selected) == 0 {
+		debugf("Skipping cell %s: no biosample selected by the filter", cell.Cell)
+		return nil, nil
+	}
+	biosamples = selected
+
+	movie := cell.Cell.MovieName
+	layout := opts.layout()
+
 	if multiplexed { // Multiplexed sample
 		for _, biosampleInfo := range biosamples {
-			// Find .bam files for each barcode
-			barcode := strings.Split(biosampleInfo.Barcode, "--")[0]
-			bamPattern := filepath.Join(hifiDir, fmt.Sprintf("*%s*.bam", barcode))
-			debugf("Looking for BAM files with pattern: %s", bamPattern)
-
-			bamFiles, err := filepath.Glob(bamPattern)
+			// Resolve the exact BAM for each barcode
+			files, err := resolveBarcodeFiles(hifiDir, movie, biosampleInfo.Barcode)
 			if err != nil {
-				debugf("Error globbing BAM files: %v", err)
+				debugf("Error resolving barcode %s: %v", biosampleInfo.Barcode, err)
+				errs = append(errs, fmt.Errorf("biosample %s (%s): %w", biosampleInfo.Name, biosampleInfo.Barcode, err))
 				continue
 			}
 
-			for _, bamFile := range bamFiles {
-				pbiFile := bamFile + ".pbi"
-				if _, err := os.Stat(pbiFile); os.IsNotExist(err) {
-					debugf("PBI file not found for BAM: %s", bamFile)
-					continue
-				}
-
-				destDir := filepath.Join(outputDir, fmt.Sprintf("Sample_%s", biosampleInfo.Name))
-				destBAM := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam", biosampleInfo.Name))
-				destPBI := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam.pbi", biosampleInfo.Name))
-
-				mappings = append(mappings, &FileMapping{
-					SourceBAM: bamFile,
-					SourcePBI: pbiFile,
-					DestBAM:   destBAM,
-					DestPBI:   destPBI,
-					BioSample: biosampleInfo.Name,
-				})
+			destBAM, destPBI, err := layout.Destination(opts.OutputDir, layoutDataFor(cell, biosampleInfo))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("biosample %s: %w", biosampleInfo.Name, err))
+				continue
 			}
+
+			mappings = append(mappings, &FileMapping{
+				SourceBAM: files.BAM,
+				SourcePBI: files.PBI,
+				DestBAM:   destBAM,
+				DestPBI:   destPBI,
+				BioSample: biosampleInfo.Name,
+				Barcode:   biosampleInfo.Barcode,
+				Cell:      cell.Cell,
+				Class:     ClassHiFi,
+			})
 		}
 	} else { // Single sample
-		// Find .bam files (assuming there's only one per biosample)
-		bamPattern := filepath.Join(hifiDir, "*.hifi_reads.bam")
-		debugf("Looking for BAM files with pattern: %s", bamPattern)
-
-		bamFiles, err := filepath.Glob(bamPattern)
+		files, err := resolveCellFiles(hifiDir, movie)
 		if err != nil {
-			debugf("Error globbing BAM files: %v", err)
-			return nil, err
-		}
-
-		debugf("Found %d BAM files", len(bamFiles))
-		if len(bamFiles) == 0 {
-			debugf("No HiFi BAM files found")
-			return nil, fmt.Errorf("no HiFi BAM files found in %s", hifiDir)
-		}
-
-		bamFile := bamFiles[0] // Choose first deterministically for single sample case.
-		pbiFile := bamFile + ".pbi"
-		if _, err := os.Stat(pbiFile); os.IsNotExist(err) {
-			return nil, fmt.Errorf("PBI file not found for BAM: %s", bamFile)
+			debugf("Error resolving cell files: %v", err)
+			return nil, fmt.Errorf("biosample %s: %w", biosamples[0].Name, err)
 		}
 
 		biosample := biosamples[0].Name
-		destDir := filepath.Join(outputDir, fmt.Sprintf("Sample_%s", biosample))
-		destBAM := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam", biosample))
-		destPBI := filepath.Join(destDir, fmt.Sprintf("%s.mod.unmapped.bam.pbi", biosample))
+		destBAM, destPBI, err := layout.Destination(opts.OutputDir, layoutDataFor(cell, biosamples[0]))
+		if err != nil {
+			return nil, fmt.Errorf("biosample %s: %w", biosample, err)
+		}
 
 		mappings = append(mappings, &FileMapping{
-			SourceBAM: bamFile,
-			SourcePBI: pbiFile,
+			SourceBAM: files.BAM,
+			SourcePBI: files.PBI,
 			DestBAM:   destBAM,
 			DestPBI:   destPBI,
 			BioSample: biosample,
+			Barcode:   biosamples[0].Barcode,
+			Cell:      cell.Cell,
+			Class:     ClassHiFi,
 		})
 	}
 
-	return mappings, nil
+	return mappings, errors.Join(errs...)
 }
 
-// IdentifyAllHiFiFiles identifies all HiFi files for all cells in a run
-// IdentifyAllHiFiFiles iterates across metadata files to aggregate all HiFi file mappings.
-func IdentifyAllHiFiFiles(cells []string, biosamples map[string][]metadata.BioSampleInfo, outputDir string) ([]*FileMapping, error) {
+// IdentifyAllHiFiFiles iterates across the cells of a run to aggregate all HiFi file mappings,
+// followed by the mappings of the optional classes in opts.Extra.
+// Mappings that could be resolved are returned even when other biosamples failed;
+// the returned error then lists every biosample that could not be resolved.
+// Destinations shared by several cells are handled according to opts.Collision.
+// With opts.Filter, only the selected biosamples and cells are resolved; collisions
+// are then detected among the selected files only. A run in which nothing matches
+// the filter yields no mappings and no error.
+// If ctx is cancelled, identification stops and ctx.Err() is returned.
+func IdentifyAllHiFiFiles(ctx context.Context, cells []*metadata.MetadataInfo, opts IdentifyOptions) ([]*FileMapping, error) {
 	var fileMappings []*FileMapping
+	var errs []error
+	var selectedCells []*metadata.MetadataInfo // Cells whose run-level files are delivered
 
-	for _, metadataPath := range cells {
-		biosampleList, ok := biosamples[metadataPath]
-		if !ok {
-			return nil, fmt.Errorf("biosample not found for metadata file: %s", metadataPath)
+	for _, cell := range cells {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-
-		mappings, err := IdentifyHiFiFiles(metadataPath, biosampleList, outputDir)
+		mappings, err := IdentifyHiFiFiles(cell, opts)
 		if err != nil {
-			// Continue processing other files; caller will evaluate final result.
-			debugf("warning while identifying files for %s: %v", metadataPath, err)
-			continue
+			// Continue processing other cells; caller will evaluate final result.
+			debugf("warning while identifying files for %s: %v", cell.FilePath, err)
+			errs = append(errs, fmt.Errorf("cell %s: %w", cell.Cell, err))
+		}
+		if opts.Filter.MatchCell(cell.Cell) && (len(mappings) > 0 || !opts.Filter.SelectsSamples()) {
+			selectedCells = append(selectedCells, cell)
 		}
 
 		fileMappings = append(fileMappings, mappings...)
 	}
 
-	if len(fileMappings) == 0 {
-		return nil, fmt.Errorf("no valid HiFi files identified")
+	if len(fileMappings) == 0 && len(errs) == 0 && opts.Filter != nil {
+		debugf("no biosample or cell matches the filter (%s)", opts.Filter)
+		return nil, nil
+	} else if len(fileMappings) == 0 {
+		errs = append(errs, fmt.Errorf("no valid HiFi files identified"))
+	}
+
+	if err := resolveCollisions(fileMappings, opts.Collision, opts.layout().ext); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Optional classes are placed relative to the final HiFi destinations.
+	extras, err := identifyExtraFiles(selectedCells, fileMappings, opts)
+	if err != nil {
+		errs = append(errs, err)
 	}
+	fileMappings = append(fileMappings, extras...)
 
-	return fileMappings, nil
+	return fileMappings, errors.Join(errs...)
 }
@@ -0,0 +1,399 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+const testMovie = "m84001_250922_110000_s1"
+
+// makeCell creates a Revio-style cell directory with the given hifi_reads files
+// and returns the MetadataInfo pointing at it.
+func makeCell(t *testing.T, files map[string]string, biosamples ...metadata.BioSampleInfo) *metadata.MetadataInfo {
+	t.Helper()
+	cellDir := filepath.Join(t.TempDir(), "r84001_20250922_100000", "1_A01")
+	for _, dir := range []string{"metadata", "hifi_reads"} {
+		if err := os.MkdirAll(filepath.Join(cellDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(cellDir, "hifi_reads", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &metadata.MetadataInfo{
+		RunName:    "r84001_20250922_100000",
+		FilePath:   filepath.Join(cellDir, "metadata", testMovie+".metadata.xml"),
+		BioSamples: biosamples,
+		Cell:       metadata.CellInfo{MovieName: testMovie, WellName: "A01", PlateNumber: 1},
+	}
+}
+
+func TestParseHiFiBAMName(t *testing.T) {
+	tests := []struct {
+		name    string
+		ok      bool
+		barcode string
+	}{
+		{testMovie + ".hifi_reads.bam", true, ""},
+		{testMovie + ".hifi_reads.bc2001--bc2002.bam", true, "bc2001--bc2002"},
+		{testMovie + ".hifi_reads.bc2001.bam", true, "bc2001--bc2001"},
+		{testMovie + ".fail_reads.bc2001--bc2001.bam", false, ""},
+		{testMovie + ".hifi_reads.bc2001--bc2001.bam.pbi", false, ""},
+	}
+	for _, tt := range tests {
+		parsed, ok := ParseHiFiBAMName(tt.name)
+		if ok != tt.ok {
+			t.Fatalf("%s: expected ok=%v got %v", tt.name, tt.ok, ok)
+		}
+		if ok && (parsed.Movie != testMovie || parsed.Barcode() != tt.barcode) {
+			t.Fatalf("%s: unexpected parse %+v", tt.name, parsed)
+		}
+	}
+}
+
+func TestIdentifyHiFiFilesExactBarcode(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.bam":     "",
+		testMovie + ".hifi_reads.bc2001--bc2001.bam.pbi": "",
+		testMovie + ".hifi_reads.bc20011--bc20011.bam":   "",
+		testMovie + ".hifi_reads.unassigned.bam":         "",
+		testMovie + ".fail_reads.bc2001--bc2001.bam":     "",
+		testMovie + ".hifi_reads.bc2002--bc2003.bam":     "",
+		testMovie + ".hifi_reads.bc2002--bc2003.bam.pbi": "",
+	},
+		metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"},
+		metadata.BioSampleInfo{Name: "B", Barcode: "bc2002--bc2003"},
+	)
+
+	mappings, err := IdentifyHiFiFiles(cell, IdentifyOptions{OutputDir: "/out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("expected 2 mappings got %d", len(mappings))
+	}
+	if filepath.Base(mappings[0].SourceBAM) != testMovie+".hifi_reads.bc2001--bc2001.bam" {
+		t.Fatalf("unexpected BAM for A: %s", mappings[0].SourceBAM)
+	}
+	if filepath.Base(mappings[1].SourceBAM) != testMovie+".hifi_reads.bc2002--bc2003.bam" {
+		t.Fatalf("unexpected BAM for B: %s", mappings[1].SourceBAM)
+	}
+}
+
+func TestIdentifyHiFiFilesFromDataSet(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.consensusreadset.xml": `<ConsensusReadSet><ExternalResources>
+  <ExternalResource MetaType="PacBio.ConsensusReadFile.ConsensusReadBamFile" ResourceId="/instrument/path/renamed.bam">
+    <FileIndices><FileIndex MetaType="PacBio.Index.PacBioIndex" ResourceId="/instrument/path/renamed.bam.pbi"/></FileIndices>
+  </ExternalResource>
+</ExternalResources></ConsensusReadSet>`,
+		"renamed.bam":     "",
+		"renamed.bam.pbi": "",
+	}, metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"})
+
+	mappings, err := IdentifyHiFiFiles(cell, IdentifyOptions{OutputDir: "/out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(mappings[0].SourceBAM) != "renamed.bam" || filepath.Base(mappings[0].SourcePBI) != "renamed.bam.pbi" {
+		t.Fatalf("unexpected mapping %+v", mappings[0])
+	}
+}
+
+func TestIdentifyHiFiFilesFromSingleBarcodeDataSet(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001.consensusreadset.xml": `<ConsensusReadSet><ExternalResources>
+  <ExternalResource MetaType="PacBio.ConsensusReadFile.ConsensusReadBamFile" ResourceId="renamed.bam"/>
+</ExternalResources></ConsensusReadSet>`,
+		"renamed.bam": "",
+	}, metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"})
+
+	mappings, err := IdentifyHiFiFiles(cell, IdentifyOptions{OutputDir: "/out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(mappings[0].SourceBAM) != "renamed.bam" {
+		t.Fatalf("unexpected mapping %+v", mappings[0])
+	}
+}
+
+func TestIdentifyHiFiFilesMissingBarcode(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.bam": "",
+	},
+		metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"},
+		metadata.BioSampleInfo{Name: "B", Barcode: "bc2009--bc2009"},
+	)
+
+	mappings, err := IdentifyHiFiFiles(cell, IdentifyOptions{OutputDir: "/out"})
+	if !errors.Is(err, ErrNoFiles) {
+		t.Fatalf("expected ErrNoFiles got %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].BioSample != "A" {
+		t.Fatalf("expected the resolvable mapping to be returned got %+v", mappings)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.bam": "",
+		testMovie + ".hifi_reads.bc2002--bc2002.bam": "",
+	},
+		metadata.BioSampleInfo{Name: "LAB1_A", Barcode: "bc2001--bc2001"},
+		metadata.BioSampleInfo{Name: "LAB1_ctrl", Barcode: "bc2002--bc2002"},
+		metadata.BioSampleInfo{Name: "LAB2_B", Barcode: "bc2009--bc2009"}, // Files missing
+	)
+
+	// The unresolvable biosample is not selected, so identification succeeds
+	filter, err := NewFilter([]string{"LAB1_*"}, []string{"re:.*_ctrl"}, nil, []string{"A01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := IdentifyOptions{OutputDir: "/out", Filter: filter}
+	mappings, err := IdentifyAllHiFiFiles(context.Background(), []*metadata.MetadataInfo{cell}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mappings) != 1 || mappings[0].BioSample != "LAB1_A" {
+		t.Fatalf("expected only LAB1_A got %+v", mappings)
+	}
+
+	// Interactive picks narrow the filter further
+	if opts.Filter.Only([]string{"LAB1_ctrl"}).MatchSample("LAB1_ctrl") || !opts.Filter.Only(nil).SelectsSamples() {
+		t.Fatal("picked biosamples must be combined with the patterns")
+	}
+
+	// A run in which nothing matches yields no files but no error, so that it
+	// does not stop other runs of the session
+	other := makeCell(t, map[string]string{testMovie + ".hifi_reads.bc2003--bc2003.bam": ""},
+		metadata.BioSampleInfo{Name: "LAB3_C", Barcode: "bc2003--bc2003"})
+	for run, want := range map[*metadata.MetadataInfo]int{cell: 1, other: 0} {
+		mappings, err := IdentifyAllHiFiFiles(context.Background(), []*metadata.MetadataInfo{run}, opts)
+		if err != nil || len(mappings) != want {
+			t.Fatalf("%s: expected %d mappings and no error, got %d, %v", run.BioSamples[0].Name, want, len(mappings), err)
+		}
+	}
+
+	if f, err := NewFilter(nil, nil, nil, nil); f != nil || err != nil {
+		t.Fatalf("expected no filter got %v, %v", f, err)
+	}
+	if _, err := NewFilter([]string{"re:("}, nil, nil, nil); err == nil {
+		t.Fatal("expected error for invalid regular expression")
+	}
+	if _, err := NewFilter(nil, nil, []string{"[a-"}, nil); err == nil {
+		t.Fatal("expected error for invalid glob")
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	newMappings := func() []*FileMapping {
+		return []*FileMapping{
+			{BioSample: "X", DestBAM: "/out/Sample_X/X.mod.unmapped.bam", DestPBI: "/out/Sample_X/X.mod.unmapped.bam.pbi", Cell: metadata.CellInfo{MovieName: "m1"}},
+			{BioSample: "X", DestBAM: "/out/Sample_X/X.mod.unmapped.bam", DestPBI: "/out/Sample_X/X.mod.unmapped.bam.pbi", Cell: metadata.CellInfo{MovieName: "m2"}},
+			{BioSample: "Y", DestBAM: "/out/Sample_Y/Y.mod.unmapped.bam", DestPBI: "/out/Sample_Y/Y.mod.unmapped.bam.pbi", Cell: metadata.CellInfo{MovieName: "m1"}},
+		}
+	}
+
+	mappings := newMappings()
+	if err := resolveCollisions(mappings, CollisionSuffix, DefaultLayout().ext); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mappings[0].DestBAM != "/out/Sample_X/X.m1.mod.unmapped.bam" || mappings[1].DestPBI != "/out/Sample_X/X.m2.mod.unmapped.bam.pbi" {
+		t.Fatalf("unexpected suffixed destinations %s %s", mappings[0].DestBAM, mappings[1].DestPBI)
+	}
+	if !mappings[0].MultiCell || mappings[2].MultiCell {
+		t.Fatal("expected only X to be marked multi-cell")
+	}
+
+	mappings = newMappings()
+	if err := resolveCollisions(mappings, CollisionSubfolder, DefaultLayout().ext); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mappings[1].DestBAM != "/out/Sample_X/m2/X.mod.unmapped.bam" {
+		t.Fatalf("unexpected subfolder destination %s", mappings[1].DestBAM)
+	}
+
+	if err := resolveCollisions(newMappings(), CollisionFail, DefaultLayout().ext); !errors.Is(err, ErrDestinationCollision) {
+		t.Fatalf("expected ErrDestinationCollision got %v", err)
+	}
+}
+
+func TestInsertSuffix(t *testing.T) {
+	tests := []struct {
+		path, ext, want string
+	}{
+		{"/out/HG002.rep1.mod.unmapped.bam", ".mod.unmapped.bam", "/out/HG002.rep1.m1.mod.unmapped.bam"},
+		{"/out/HG002.rep1.mod.unmapped.bam.pbi", ".mod.unmapped.bam", "/out/HG002.rep1.m1.mod.unmapped.bam.pbi"},
+		{"/out/HG002.rep1.bam", "", "/out/HG002.rep1.m1.bam"},
+		{"/out/HG002.rep1.bam.pbi", "", "/out/HG002.rep1.m1.bam.pbi"},
+		{"/out/HG002.rep1.hifi", ".hifi", "/out/HG002.rep1.m1.hifi"},
+		{"/out/HG002", "", "/out/HG002.m1"},
+	}
+	for _, tt := range tests {
+		if got := insertSuffix(tt.path, "m1", tt.ext); got != tt.want {
+			t.Fatalf("insertSuffix(%q, %q) = %q, want %q", tt.path, tt.ext, got, tt.want)
+		}
+	}
+
+	for template, want := range map[string]string{
+		DefaultFileTemplate:               ".mod.unmapped.bam",
+		"{{.BioSample}}.{{.Barcode}}.bam": ".bam",
+		"{{.BioSample}}_hifi.bam ":        ".bam",
+		"{{.BioSample}}":                  "",
+	} {
+		layout, err := NewLayout("", template)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if layout.ext != want {
+			t.Fatalf("extension of %q = %q, want %q", template, layout.ext, want)
+		}
+	}
+}
+
+func TestLayoutTemplates(t *testing.T) {
+	layout, err := NewLayout("{{.RunName}}/{{.Well}}/{{.BioSample}}", "{{.BioSample}}.{{.Barcode}}.bam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bam, pbi, err := layout.Destination("/out", LayoutData{RunName: "R1", Well: "1_A01", BioSample: "a/b", Barcode: "bc2001--bc2001"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bam != "/out/R1/1_A01/a_b/a_b.bc2001--bc2001.bam" || pbi != bam+".pbi" {
+		t.Fatalf("unexpected destination %s %s", bam, pbi)
+	}
+
+	for _, tmpl := range [][2]string{
+		{"{{.Unknown}}", DefaultFileTemplate},
+		{"../escape", DefaultFileTemplate},
+		{DefaultDirTemplate, "sub/{{.BioSample}}.bam"},
+		{DefaultDirTemplate, "{{.BioSample"},
+	} {
+		if _, err := NewLayout(tmpl[0], tmpl[1]); err == nil {
+			t.Fatalf("expected templates %q / %q to be rejected", tmpl[0], tmpl[1])
+		}
+	}
+}
+
+func TestParseFileClasses(t *testing.T) {
+	classes, err := ParseFileClasses("metadata, fail_reads,hifi_reads")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(classes) != 2 || classes[0] != ClassFailReads || classes[1] != ClassMetadata {
+		t.Fatalf("unexpected classes %v", classes)
+	}
+	if classes, _ := ParseFileClasses("all"); len(classes) != len(ExtraClasses) {
+		t.Fatalf("expected all classes got %v", classes)
+	}
+	if _, err := ParseFileClasses("subreads"); err == nil {
+		t.Fatal("expected error for unknown class")
+	}
+}
+
+func TestIdentifyExtraFiles(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.bam":     "",
+		testMovie + ".hifi_reads.bc2001--bc2001.bam.pbi": "",
+		testMovie + ".hifi_reads.bc2001--bc2001.consensusreadset.xml": `<ConsensusReadSet><ExternalResources>
+  <ExternalResource MetaType="PacBio.ConsensusReadFile.ConsensusReadBamFile" ResourceId="` + testMovie + `.hifi_reads.bc2001--bc2001.bam"/>
+</ExternalResources></ConsensusReadSet>`,
+		testMovie + ".hifi_reads.unassigned.bam":     "",
+		testMovie + ".hifi_reads.unassigned.bam.pbi": "",
+	}, metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"})
+	cellDir := filepath.Dir(filepath.Dir(cell.FilePath))
+	for name, content := range map[string]string{
+		"fail_reads/" + testMovie + ".fail_reads.bc2001--bc2001.bam": "",
+		"statistics/" + testMovie + ".ccs_report.json":               "{}",
+		"statistics/plots/" + testMovie + ".png":                     "",
+		"metadata/" + testMovie + ".metadata.xml":                    "<Metadata/>",
+	} {
+		path := filepath.Join(cellDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second collection of the same cell shares its directory and metadata file;
+	// its extras must not be delivered twice.
+	other := *cell
+	other.BioSamples = nil
+	opts := IdentifyOptions{OutputDir: "/out", Extra: ExtraClasses}
+	mappings, err := IdentifyAllHiFiFiles(context.Background(), []*metadata.MetadataInfo{cell, &other}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sampleDir := filepath.Dir(mappings[0].DestBAM)
+	want := map[string]string{
+		testMovie + ".fail_reads.bc2001--bc2001.bam":                  filepath.Join(sampleDir, "fail_reads"),
+		testMovie + ".hifi_reads.unassigned.bam":                      "/out/unassigned",
+		testMovie + ".ccs_report.json":                                "/out/statistics/" + testMovie,
+		testMovie + ".png":                                            "/out/statistics/" + testMovie + "/plots",
+		testMovie + ".hifi_reads.bc2001--bc2001.consensusreadset.xml": sampleDir,
+		testMovie + ".metadata.xml":                                   "/out/metadata",
+	}
+	if len(mappings) != 1+len(want) {
+		t.Fatalf("expected %d mappings got %d", 1+len(want), len(mappings))
+	}
+	for _, m := range mappings[1:] {
+		name := filepath.Base(m.SourceBAM)
+		if m.IsHiFi() || filepath.Dir(m.DestBAM) != want[name] || filepath.Base(m.DestBAM) != name {
+			t.Fatalf("unexpected %s mapping %s -> %s", m.Class, m.SourceBAM, m.DestBAM)
+		}
+	}
+	for _, m := range mappings {
+		wantFiles := 1
+		if m.IsHiFi() || m.Class == ClassUnassigned {
+			wantFiles = 2
+		}
+		if len(m.Files()) != wantFiles {
+			t.Fatalf("%s: expected %d files got %+v", m.Label(), wantFiles, m.Files())
+		}
+	}
+}
+
+func TestIdentifyExtraFilesSingleBarcodeNames(t *testing.T) {
+	cell := makeCell(t, map[string]string{
+		testMovie + ".hifi_reads.bc2001--bc2001.bam":     "",
+		testMovie + ".hifi_reads.bc2001--bc2001.bam.pbi": "",
+		testMovie + ".hifi_reads.bc2001.consensusreadset.xml": `<ConsensusReadSet><ExternalResources>
+  <ExternalResource MetaType="PacBio.ConsensusReadFile.ConsensusReadBamFile" ResourceId="` + testMovie + `.hifi_reads.bc2001--bc2001.bam"/>
+</ExternalResources></ConsensusReadSet>`,
+	}, metadata.BioSampleInfo{Name: "A", Barcode: "bc2001--bc2001"})
+	failReads := filepath.Join(filepath.Dir(filepath.Dir(cell.FilePath)), "fail_reads", testMovie+".fail_reads.bc2001.bam")
+	if err := os.MkdirAll(filepath.Dir(failReads), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(failReads, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := IdentifyOptions{OutputDir: "/out", Extra: []FileClass{ClassFailReads, ClassDataSet}}
+	mappings, err := IdentifyAllHiFiFiles(context.Background(), []*metadata.MetadataInfo{cell}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mappings) != 3 {
+		t.Fatalf("expected 3 mappings got %d", len(mappings))
+	}
+	want := map[FileClass]string{
+		ClassFailReads: testMovie + ".fail_reads.bc2001.bam",
+		ClassDataSet:   testMovie + ".hifi_reads.bc2001.consensusreadset.xml",
+	}
+	for _, m := range mappings[1:] {
+		if filepath.Base(m.SourceBAM) != want[m.Class] || m.BioSample != "A" {
+			t.Fatalf("unexpected %s mapping %s -> %s", m.Class, m.SourceBAM, m.DestBAM)
+		}
+	}
+}
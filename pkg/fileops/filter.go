@@ -0,0 +1,164 @@
+package fileops
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+// regexPrefix marks a filter pattern as a regular expression rather than a glob.
+const regexPrefix = "re:"
+
+// pattern matches names by shell glob or, with the "re:" prefix, by regular expression.
+type pattern struct {
+	text string
+	re   *regexp.Regexp // nil for globs
+}
+
+// parsePattern validates a glob or "re:" regular expression. Regular
+// expressions must match the whole name.
+func parsePattern(s string) (pattern, error) {
+	if expr, ok := strings.CutPrefix(s, regexPrefix); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return pattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return pattern{text: s, re: re}, nil
+	}
+	if _, err := path.Match(s, ""); err != nil {
+		return pattern{}, fmt.Errorf("invalid pattern %q: %w", s, err)
+	}
+	return pattern{text: s}, nil
+}
+
+// match reports whether any of names matches p. Empty names never match.
+func (p pattern) match(names ...string) bool {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if p.re != nil {
+			if p.re.MatchString(name) {
+				return true
+			}
+		} else if ok, _ := path.Match(p.text, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter selects the biosamples and cells whose files are identified. A nil
+// Filter selects everything. Biosamples and cells that are not selected are
+// not resolved, so their missing files do not cause errors.
+type Filter struct {
+	samples []pattern       // Biosample names to include; empty means all
+	exclude []pattern       // Biosample names to leave out
+	cells   []pattern       // Movie names or cell barcodes to include
+	wells   []pattern       // Well positions to include, e.g. 1_A01 or A01
+	only    map[string]bool // Exact biosample names picked interactively; nil means all
+}
+
+// NewFilter builds a filter from sample, excluded sample, cell and well patterns.
+// Patterns are shell globs, or regular expressions when prefixed with "re:".
+// It returns nil when no pattern is given.
+func NewFilter(samples, exclude, cells, wells []string) (*Filter, error) {
+	f := &Filter{}
+	for _, group := range []struct {
+		patterns []string
+		dest     *[]pattern
+	}{{samples, &f.samples}, {exclude, &f.exclude}, {cells, &f.cells}, {wells, &f.wells}} {
+		for _, s := range group.patterns {
+			p, err := parsePattern(s)
+			if err != nil {
+				return nil, err
+			}
+			*group.dest = append(*group.dest, p)
+		}
+	}
+	if len(f.samples)+len(f.exclude)+len(f.cells)+len(f.wells) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Only returns a copy of f that also requires biosamples to be one of names.
+func (f *Filter) Only(names []string) *Filter {
+	c := &Filter{only: make(map[string]bool, len(names))}
+	if f != nil {
+		c.samples, c.exclude, c.cells, c.wells = f.samples, f.exclude, f.cells, f.wells
+	}
+	for _, name := range names {
+		c.only[name] = true
+	}
+	return c
+}
+
+// SelectsSamples reports whether f leaves out biosamples by name.
+func (f *Filter) SelectsSamples() bool {
+	return f != nil && (len(f.samples) > 0 || len(f.exclude) > 0 || f.only != nil)
+}
+
+// MatchCell reports whether the files of cell are selected.
+func (f *Filter) MatchCell(cell metadata.CellInfo) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.cells) > 0 && !anyMatch(f.cells, cell.MovieName, cell.CellBarcode) {
+		return false
+	}
+	return len(f.wells) == 0 || anyMatch(f.wells, cell.Position(), cell.WellName)
+}
+
+// MatchSample reports whether the files of the biosample name are selected.
+func (f *Filter) MatchSample(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.only != nil && !f.only[name] {
+		return false
+	}
+	if len(f.samples) > 0 && !anyMatch(f.samples, name) {
+		return false
+	}
+	return !anyMatch(f.exclude, name)
+}
+
+// String describes the filter for reports, e.g. "sample LAB1_*, not sample *_ctrl".
+func (f *Filter) String() string {
+	if f == nil {
+		return "none"
+	}
+	var parts []string
+	for _, group := range []struct {
+		label    string
+		patterns []pattern
+	}{{"sample", f.samples}, {"not sample", f.exclude}, {"cell", f.cells}, {"well", f.wells}} {
+		for _, p := range group.patterns {
+			parts = append(parts, group.label+" "+p.text)
+		}
+	}
+	if f.only != nil {
+		names := make([]string, 0, len(f.only))
+		for name := range f.only {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts = append(parts, "picked "+strings.Join(names, ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// anyMatch reports whether any pattern matches any of names.
+func anyMatch(patterns []pattern, names ...string) bool {
+	for _, p := range patterns {
+		if p.match(names...) {
+			return true
+		}
+	}
+	return false
+}
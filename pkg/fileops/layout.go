@@ -0,0 +1,180 @@
+package fileops
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+const (
+	// DefaultDirTemplate is the sample directory, relative to the output directory.
+	DefaultDirTemplate = "Sample_{{.BioSample}}"
+	// DefaultFileTemplate is the BAM file name inside the sample directory.
+	// The PBI index is always named after the BAM with a .pbi extension.
+	DefaultFileTemplate = "{{.BioSample}}.mod.unmapped.bam"
+)
+
+// LayoutData is the data available to destination templates.
+type LayoutData struct {
+	RunName    string
+	RunDate    string // YYYY-MM-DD in local time; empty when unknown
+	BioSample  string
+	Barcode    string // Barcode pair, e.g. bc2001--bc2001; empty for non-multiplexed cells
+	Well       string // Plate/well position, e.g. 1_A01
+	Movie      string
+	Instrument string
+}
+
+// Layout renders destination paths from directory and file name templates.
+type Layout struct {
+	dir  *template.Template
+	file *template.Template
+	ext  string // Literal extension ending the file template, e.g. .mod.unmapped.bam
+}
+
+// sampleLayoutData is used to validate templates before any copy is planned.
+var sampleLayoutData = LayoutData{
+	RunName:    "r84001_20250922_100000",
+	RunDate:    "2025-09-22",
+	BioSample:  "SAMPLE",
+	Barcode:    "bc2001--bc2001",
+	Well:       "1_A01",
+	Movie:      "m84001_250922_110000_s1",
+	Instrument: "84001",
+}
+
+// NewLayout parses and validates destination templates. Empty templates fall back to the defaults.
+func NewLayout(dirTemplate, fileTemplate string) (*Layout, error) {
+	if strings.TrimSpace(dirTemplate) == "" {
+		dirTemplate = DefaultDirTemplate
+	}
+	if strings.TrimSpace(fileTemplate) == "" {
+		fileTemplate = DefaultFileTemplate
+	}
+
+	dir, err := template.New("dir").Option("missingkey=error").Parse(dirTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory template: %w", err)
+	}
+	file, err := template.New("file").Option("missingkey=error").Parse(fileTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file template: %w", err)
+	}
+
+	l := &Layout{dir: dir, file: file, ext: templateExtension(file)}
+	if _, err := l.render(sampleLayoutData); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DefaultLayout returns the layout Sample_<name>/<name>.mod.unmapped.bam.
+func DefaultLayout() *Layout {
+	l, err := NewLayout(DefaultDirTemplate, DefaultFileTemplate)
+	if err != nil {
+		panic(err) // The default templates are constant and known to be valid.
+	}
+	return l
+}
+
+// Destination returns the destination BAM and PBI paths under outputDir.
+func (l *Layout) Destination(outputDir string, data LayoutData) (bam string, pbi string, err error) {
+	rel, err := l.render(data)
+	if err != nil {
+		return "", "", err
+	}
+	bam = filepath.Join(outputDir, rel)
+	return bam, bam + ".pbi", nil
+}
+
+// render executes both templates and returns the BAM path relative to the output directory.
+func (l *Layout) render(data LayoutData) (string, error) {
+	data = data.sanitized()
+
+	var dirBuf, fileBuf bytes.Buffer
+	if err := l.dir.Execute(&dirBuf, data); err != nil {
+		return "", fmt.Errorf("directory template: %w", err)
+	}
+	if err := l.file.Execute(&fileBuf, data); err != nil {
+		return "", fmt.Errorf("file template: %w", err)
+	}
+
+	dir := filepath.Clean(filepath.FromSlash(strings.TrimSpace(dirBuf.String())))
+	file := strings.TrimSpace(fileBuf.String())
+
+	switch {
+	case filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)):
+		return "", fmt.Errorf("directory template must stay inside the output directory, got %q", dir)
+	case file == "" || file == "." || file == "..":
+		return "", fmt.Errorf("file template rendered an empty file name")
+	case strings.ContainsAny(file, `/\`):
+		return "", fmt.Errorf("file template must not contain path separators, got %q", file)
+	}
+
+	return filepath.Join(dir, file), nil
+}
+
+// templateExtension returns the literal text of a file name template from the
+// first dot after its last action, or "" when the template ends in an action.
+func templateExtension(t *template.Template) string {
+	nodes := t.Tree.Root.Nodes
+	if len(nodes) == 0 {
+		return ""
+	}
+	text, ok := nodes[len(nodes)-1].(*parse.TextNode)
+	if !ok {
+		return ""
+	}
+	literal := strings.TrimSpace(string(text.Text))
+	if i := strings.Index(literal, "."); i >= 0 {
+		return literal[i:]
+	}
+	return ""
+}
+
+// sanitized replaces path separators in values so they cannot escape their path component.
+func (d LayoutData) sanitized() LayoutData {
+	r := strings.NewReplacer("/", "_", `\`, "_")
+	return LayoutData{
+		RunName:    r.Replace(d.RunName),
+		RunDate:    r.Replace(d.RunDate),
+		BioSample:  r.Replace(d.BioSample),
+		Barcode:    r.Replace(d.Barcode),
+		Well:       r.Replace(d.Well),
+		Movie:      r.Replace(d.Movie),
+		Instrument: r.Replace(d.Instrument),
+	}
+}
+
+// layoutDataFor builds the template data for one biosample of a cell.
+func layoutDataFor(cell *metadata.MetadataInfo, biosample metadata.BioSampleInfo) LayoutData {
+	date := cell.StartedDate
+	if date.IsZero() {
+		date = cell.CreatedDate
+	}
+	if date.IsZero() {
+		date, _ = metadata.InferDateFromRunName(cell.RunName)
+	}
+	runDate := ""
+	if !date.IsZero() {
+		runDate = date.Local().Format("2006-01-02")
+	}
+	instrument := cell.Cell.InstrumentID
+	if instrument == "" {
+		instrument = cell.Cell.InstrumentName
+	}
+	return LayoutData{
+		RunName:    cell.RunName,
+		RunDate:    runDate,
+		BioSample:  biosample.Name,
+		Barcode:    biosample.Barcode,
+		Well:       cell.Cell.Position(),
+		Movie:      cell.Cell.MovieName,
+		Instrument: instrument,
+	}
+}
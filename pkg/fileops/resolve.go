@@ -0,0 +1,178 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/schnurbe/revio-copy/pkg/metadata"
+)
+
+var (
+	// ErrNoFiles is returned when a barcode or cell resolves to no BAM file.
+	ErrNoFiles = errors.New("no matching HiFi BAM file")
+	// ErrAmbiguousFiles is returned when a barcode or cell resolves to more than one BAM file.
+	ErrAmbiguousFiles = errors.New("more than one matching HiFi BAM file")
+)
+
+// HiFiBAMName is the parsed form of a HiFi read BAM file name.
+type HiFiBAMName struct {
+	Movie      string
+	BarcodeFwd string // Empty for non-demultiplexed files
+	BarcodeRev string // Empty for non-demultiplexed files
+}
+
+// Barcode returns the barcode pair in <fwd>--<rev> form, or "" when not barcoded.
+func (n HiFiBAMName) Barcode() string {
+	if n.BarcodeFwd == "" {
+		return ""
+	}
+	return n.BarcodeFwd + "--" + n.BarcodeRev
+}
+
+// hifiBAMPattern matches <movie>.hifi_reads[.<bcFwd>[--<bcRev>]].bam. Barcode
+// names may not contain dots, so fail_reads, unassigned and similar files never
+// match a real barcode.
+var hifiBAMPattern = regexp.MustCompile(`^([^.]+)\.hifi_reads(?:\.([^.]+?)(?:--([^.]+))?)?\.bam$`)
+
+// ParseHiFiBAMName parses a HiFi BAM file name strictly. A single barcode
+// (<movie>.hifi_reads.bc2001.bam) is read as the symmetric pair bc2001--bc2001.
+func ParseHiFiBAMName(name string) (HiFiBAMName, bool) {
+	m := hifiBAMPattern.FindStringSubmatch(name)
+	if m == nil {
+		return HiFiBAMName{}, false
+	}
+	parsed := HiFiBAMName{Movie: m[1], BarcodeFwd: m[2], BarcodeRev: m[3]}
+	if parsed.BarcodeFwd != "" && parsed.BarcodeRev == "" {
+		parsed.BarcodeRev = parsed.BarcodeFwd
+	}
+	return parsed, true
+}
+
+// resolvedFile is a BAM file together with its PBI index.
+type resolvedFile struct {
+	BAM string
+	PBI string
+}
+
+// resolveBarcodeFiles returns the BAM/PBI pair for one barcode of a cell. The
+// per-barcode dataset XML is authoritative; strict file name matching is used
+// when no dataset XML exists.
+func resolveBarcodeFiles(hifiDir, movie, barcode string) (resolvedFile, error) {
+	if movie != "" {
+		for _, bc := range metadata.BarcodeFileNames(barcode) {
+			dsPath := filepath.Join(hifiDir, fmt.Sprintf("%s.hifi_reads.%s.consensusreadset.xml", movie, bc))
+			if _, err := os.Stat(dsPath); err != nil {
+				continue
+			}
+			debugf("Resolving barcode %s from dataset %s", barcode, dsPath)
+			return resolveFromDataSet(dsPath)
+		}
+	}
+
+	return resolveByName(hifiDir, movie, metadata.NormalizeBarcode(barcode))
+}
+
+// resolveCellFiles returns the BAM/PBI pair of a non-multiplexed cell.
+func resolveCellFiles(hifiDir, movie string) (resolvedFile, error) {
+	if movie != "" {
+		dsPath := filepath.Join(hifiDir, movie+".hifi_reads.consensusreadset.xml")
+		if _, err := os.Stat(dsPath); err == nil {
+			debugf("Resolving cell files from dataset %s", dsPath)
+			return resolveFromDataSet(dsPath)
+		}
+	}
+	return resolveByName(hifiDir, movie, "")
+}
+
+// resolveFromDataSet reads the BAM ExternalResource of a dataset XML file.
+func resolveFromDataSet(dsPath string) (resolvedFile, error) {
+	ds, err := metadata.ParseDataSetFile(dsPath)
+	if err != nil {
+		return resolvedFile{}, fmt.Errorf("reading %s: %w", filepath.Base(dsPath), err)
+	}
+
+	var files []resolvedFile
+	for _, res := range ds.Resources {
+		if !res.IsBAM() {
+			continue
+		}
+		bam := localResourcePath(dsPath, res.Path)
+		pbi := res.PBI()
+		if pbi == "" {
+			pbi = bam + ".pbi"
+		} else {
+			pbi = localResourcePath(dsPath, pbi)
+		}
+		files = append(files, resolvedFile{BAM: bam, PBI: pbi})
+	}
+
+	switch len(files) {
+	case 0:
+		return resolvedFile{}, fmt.Errorf("%w in dataset %s", ErrNoFiles, filepath.Base(dsPath))
+	case 1:
+		return files[0], nil
+	default:
+		return resolvedFile{}, fmt.Errorf("%w in dataset %s (%d BAM resources)", ErrAmbiguousFiles, filepath.Base(dsPath), len(files))
+	}
+}
+
+// localResourcePath maps a resource path written by the instrument onto this host.
+// Absolute paths from the instrument often do not exist here, in which case the
+// file is expected next to the dataset XML.
+func localResourcePath(dsPath, resourcePath string) string {
+	if _, err := os.Stat(resourcePath); err == nil {
+		return resourcePath
+	}
+	local := filepath.Join(filepath.Dir(dsPath), filepath.Base(resourcePath))
+	if _, err := os.Stat(local); err == nil {
+		return local
+	}
+	return resourcePath
+}
+
+// resolveByName finds the BAM whose strictly parsed name matches movie (when known)
+// and barcode pair (empty for non-barcoded files).
+func resolveByName(hifiDir, movie, pair string) (resolvedFile, error) {
+	entries, err := os.ReadDir(hifiDir)
+	if err != nil {
+		return resolvedFile{}, err
+	}
+
+	var matches []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parsed, ok := ParseHiFiBAMName(e.Name())
+		if !ok {
+			continue
+		}
+		if movie != "" && parsed.Movie != movie {
+			continue
+		}
+		if parsed.Barcode() != pair {
+			continue
+		}
+		matches = append(matches, filepath.Join(hifiDir, e.Name()))
+	}
+	sort.Strings(matches)
+
+	what := "cell"
+	if pair != "" {
+		what = "barcode " + pair
+	}
+	switch len(matches) {
+	case 0:
+		return resolvedFile{}, fmt.Errorf("%w for %s in %s", ErrNoFiles, what, hifiDir)
+	case 1:
+		debugf("Resolved %s by file name: %s", what, matches[0])
+		return resolvedFile{BAM: matches[0], PBI: matches[0] + ".pbi"}, nil
+	default:
+		return resolvedFile{}, fmt.Errorf("%w for %s: %s", ErrAmbiguousFiles, what, strings.Join(matches, ", "))
+	}
+}
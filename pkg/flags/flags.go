@@ -8,6 +8,23 @@ var (
 	runName    string
 	debugMode  bool
 	dryRunMode bool
+
+	collisionPolicy string
+	dirTemplate     string
+	fileTemplate    string
+
+	copyBackend  string
+	checksumList string
+	verifyCopies bool
+	copyJobs     int
+	bwLimit      string
+	resumeMode   bool
+	stateDir     string
+	onInterrupt  string
+	force        bool
+	spaceMargin  string
+	sumFiles     string
+	includeList  string
 )
 
 // GetDebugMode reports whether debug output is enabled.
@@ -29,3 +46,91 @@ func SetFlags(output string, run string, debug bool, dryRun bool) {
 	debugMode = debug
 	dryRunMode = dryRun
 }
+
+// GetCollisionPolicy returns the policy for destinations shared by several cells.
+func GetCollisionPolicy() string { return collisionPolicy }
+
+// SetCollisionPolicy updates the destination collision policy.
+func SetCollisionPolicy(policy string) { collisionPolicy = policy }
+
+// GetDirTemplate returns the destination directory template (empty means default).
+func GetDirTemplate() string { return dirTemplate }
+
+// GetFileTemplate returns the destination BAM file name template (empty means default).
+func GetFileTemplate() string { return fileTemplate }
+
+// SetLayoutTemplates updates the destination layout templates.
+func SetLayoutTemplates(dir string, file string) {
+	dirTemplate = dir
+	fileTemplate = file
+}
+
+// GetCopyBackend returns the copy backend name (auto, native or rclone).
+func GetCopyBackend() string { return copyBackend }
+
+// GetChecksums returns the comma-separated checksum algorithms computed while copying.
+func GetChecksums() string { return checksumList }
+
+// GetVerifyCopies reports whether destinations are re-read and verified after copying.
+func GetVerifyCopies() bool { return verifyCopies }
+
+// SetCopyOptions updates the copy backend settings.
+func SetCopyOptions(backend string, checksums string, verify bool) {
+	copyBackend = backend
+	checksumList = checksums
+	verifyCopies = verify
+}
+
+// GetJobs returns the number of concurrent copy workers.
+func GetJobs() int { return copyJobs }
+
+// GetBandwidthLimit returns the global bandwidth limit (e.g. "100M"; empty means unlimited).
+func GetBandwidthLimit() string { return bwLimit }
+
+// SetConcurrency updates the worker count and bandwidth limit.
+func SetConcurrency(jobs int, limit string) {
+	copyJobs = jobs
+	bwLimit = limit
+}
+
+// GetResumeMode reports whether already verified destinations are skipped.
+func GetResumeMode() bool { return resumeMode }
+
+// SetResumeMode updates the resume mode.
+func SetResumeMode(resume bool) { resumeMode = resume }
+
+// GetStateDir returns the configured state directory (empty means <output>/.revio-copy).
+func GetStateDir() string { return stateDir }
+
+// SetStateDir updates the state directory.
+func SetStateDir(dir string) { stateDir = dir }
+
+// GetInterruptMode returns what to do with copies in progress on SIGINT/SIGTERM (finish or abort).
+func GetInterruptMode() string { return onInterrupt }
+
+// SetInterruptMode updates the interrupt mode.
+func SetInterruptMode(mode string) { onInterrupt = mode }
+
+// GetForce reports whether failed pre-flight checks should be ignored.
+func GetForce() bool { return force }
+
+// GetSpaceMargin returns the free space to leave on destination filesystems (e.g. "5%" or "100G").
+func GetSpaceMargin() string { return spaceMargin }
+
+// SetPreflight updates the pre-flight check settings.
+func SetPreflight(forceCopy bool, margin string) {
+	force = forceCopy
+	spaceMargin = margin
+}
+
+// GetSumFiles returns the algorithms of the checksum files written per delivery (e.g. "md5,sha256" or "none").
+func GetSumFiles() string { return sumFiles }
+
+// SetSumFiles updates the checksum file algorithms.
+func SetSumFiles(algos string) { sumFiles = algos }
+
+// GetInclude returns the comma-separated optional file classes delivered with the HiFi reads.
+func GetInclude() string { return includeList }
+
+// SetInclude updates the optional file classes.
+func SetInclude(classes string) { includeList = classes }
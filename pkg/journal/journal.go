@@ -0,0 +1,282 @@
+// Package journal writes and replays the JSON-lines record of a copy session.
+// Each delivery gets one journal file holding the copy plan followed by one
+// event per file; it is used to resume after a crash and as provenance.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+// EventType identifies a journal line.
+type EventType string
+
+const (
+	// EventPlan records the full copy plan at the start of a session.
+	EventPlan EventType = "plan"
+	// EventResume marks that a later process continued the session.
+	EventResume EventType = "resume"
+	// EventStart records that a file copy started.
+	EventStart EventType = "start"
+	// EventDone records a verified copy.
+	EventDone EventType = "done"
+	// EventSkip records a destination that was already present and verified.
+	EventSkip EventType = "skip"
+	// EventError records a failed copy.
+	EventError EventType = "error"
+	// EventEnd records the end of a session with its totals.
+	EventEnd EventType = "end"
+)
+
+// Event is one line of the journal.
+type Event struct {
+	Time          time.Time              `json:"time"`
+	Type          EventType              `json:"type"`
+	Session       string                 `json:"session"`
+	Run           string                 `json:"run,omitempty"`
+	OutputDir     string                 `json:"output_dir,omitempty"`
+	Filter        string                 `json:"filter,omitempty"` // Biosamples and cells a plan is limited to
+	Mappings      []*fileops.FileMapping `json:"mappings,omitempty"`
+	BioSample     string                 `json:"biosample,omitempty"`
+	Source        string                 `json:"source,omitempty"`
+	Dest          string                 `json:"dest,omitempty"`
+	Bytes         int64                  `json:"bytes,omitempty"`
+	SourceSize    int64                  `json:"source_size,omitempty"`
+	SourceModTime *time.Time             `json:"source_mod_time,omitempty"`
+	DestModTime   *time.Time             `json:"dest_mod_time,omitempty"`
+	Checksums     copyfiles.Checksums    `json:"checksums,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	Copied        int                    `json:"copied,omitempty"`
+	Skipped       int                    `json:"skipped,omitempty"`
+	Failed        int                    `json:"failed,omitempty"`
+}
+
+// Journal appends events to a session's journal file. It implements copyfiles.Recorder.
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	enc     *json.Encoder
+	path    string
+	session string
+}
+
+// Dir returns the journal directory inside a state directory.
+func Dir(stateDir string) string { return filepath.Join(stateDir, "journal") }
+
+// NewSessionID returns a sortable session identifier for a run.
+func NewSessionID(run string, now time.Time) string {
+	id := now.UTC().Format("20060102T150405Z")
+	if run != "" {
+		id += "_" + strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(run)
+	}
+	return id
+}
+
+// Create starts a new journal for session in stateDir.
+func Create(stateDir, session string) (*Journal, error) {
+	dir := Dir(stateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating journal directory: %w", err)
+	}
+	return open(filepath.Join(dir, session+".jsonl"), session, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+}
+
+// Append reopens an existing journal to continue its session.
+func Append(path string) (*Journal, error) {
+	session := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	return open(path, session, os.O_APPEND|os.O_WRONLY)
+}
+
+func open(path, session string, flag int) (*Journal, error) {
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	return &Journal{file: f, enc: json.NewEncoder(f), path: path, session: session}, nil
+}
+
+// Path returns the journal file path.
+func (j *Journal) Path() string { return j.path }
+
+// write appends one event and flushes it to disk so it survives a crash.
+func (j *Journal) write(e Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.Time = time.Now()
+	e.Session = j.session
+	if err := j.enc.Encode(e); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Plan records the copy plan. filter describes the biosamples and cells the
+// plan is limited to; it is empty when the plan covers the whole run.
+func (j *Journal) Plan(run, outputDir, filter string, mappings []*fileops.FileMapping) error {
+	return j.write(Event{Type: EventPlan, Run: run, OutputDir: outputDir, Filter: filter, Mappings: mappings})
+}
+
+// Resumed records that the session is being continued.
+func (j *Journal) Resumed() error {
+	return j.write(Event{Type: EventResume})
+}
+
+// FileStarted implements copyfiles.Recorder.
+func (j *Journal) FileStarted(m *fileops.FileMapping, src, dest string) {
+	j.report(j.write(Event{Type: EventStart, BioSample: m.BioSample, Source: src, Dest: dest}))
+}
+
+// FileDone implements copyfiles.Recorder.
+func (j *Journal) FileDone(m *fileops.FileMapping, result *copyfiles.FileResult, skipped bool) {
+	e := Event{Type: EventDone, BioSample: m.BioSample, Source: result.Source, Dest: result.Dest,
+		Bytes: result.Bytes, Checksums: result.Checksums}
+	if skipped {
+		e.Type = EventSkip
+	}
+	if info, err := os.Stat(result.Dest); err == nil {
+		modTime := info.ModTime()
+		e.DestModTime = &modTime
+	}
+	if info, err := os.Stat(result.Source); err == nil {
+		modTime := info.ModTime()
+		e.SourceSize, e.SourceModTime = info.Size(), &modTime
+	}
+	j.report(j.write(e))
+}
+
+// FileFailed implements copyfiles.Recorder.
+func (j *Journal) FileFailed(m *fileops.FileMapping, src, dest string, err error) {
+	j.report(j.write(Event{Type: EventError, BioSample: m.BioSample, Source: src, Dest: dest, Error: err.Error()}))
+}
+
+// report prints journal write failures; a broken journal must not abort copies.
+func (j *Journal) report(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: writing journal %s: %v\n", j.path, err)
+	}
+}
+
+// Close records the session totals and closes the file.
+func (j *Journal) Close(copied, skipped, failed int) error {
+	werr := j.write(Event{Type: EventEnd, Copied: copied, Skipped: skipped, Failed: failed})
+	return errors.Join(werr, j.file.Close())
+}
+
+// Latest returns the journal of the most recently started session in stateDir.
+// Sessions are ordered by the start time in their ID, so appending to an older
+// journal on resume does not make it the latest.
+func Latest(stateDir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(Dir(stateDir), "*.jsonl"))
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no journal found in %s", Dir(stateDir))
+	}
+	sort.Strings(paths) // Session IDs start with a UTC timestamp
+	return paths[len(paths)-1], nil
+}
+
+// Replay is the state of a session reconstructed from its journal.
+type Replay struct {
+	Path      string
+	Session   string
+	Run       string
+	OutputDir string
+	Filter    string // Biosamples and cells the plan is limited to; empty for the whole run
+	Mappings  []*fileops.FileMapping
+	Completed map[string]Event // Last done/skip event per destination
+	Failed    map[string]Event // Last error event per destination, if not completed later
+	Finished  bool             // The last session wrote an end event
+}
+
+// Read replays a journal file. A truncated last line (crash mid-write) is ignored.
+func Read(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Replay{Path: path, Completed: make(map[string]Event), Failed: make(map[string]Event)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		switch e.Type {
+		case EventPlan:
+			r.Session, r.Run, r.OutputDir, r.Filter, r.Mappings = e.Session, e.Run, e.OutputDir, e.Filter, e.Mappings
+			r.Finished = false
+		case EventResume, EventStart:
+			r.Finished = false
+		case EventDone, EventSkip:
+			r.Completed[e.Dest] = e
+			delete(r.Failed, e.Dest)
+		case EventError:
+			r.Failed[e.Dest] = e
+			delete(r.Completed, e.Dest)
+		case EventEnd:
+			r.Finished = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if r.Mappings == nil {
+		return nil, fmt.Errorf("journal %s has no copy plan", path)
+	}
+	return r, nil
+}
+
+// Pending returns the number of planned files not yet completed.
+func (r *Replay) Pending() int {
+	n := 0
+	for _, m := range r.Mappings {
+		for _, f := range m.Files() {
+			if _, ok := r.Completed[f.Dest]; !ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// SeedManifest adds the journal's completed files to manifest so a resumed
+// copy skips them without re-reading, provided they are unchanged on disk.
+func (r *Replay) SeedManifest(manifest *copyfiles.Manifest) {
+	for dest, e := range r.Completed {
+		if e.DestModTime == nil || e.SourceModTime == nil {
+			continue
+		}
+		if _, ok := manifest.Lookup(dest); ok {
+			continue
+		}
+		manifest.Add(dest, copyfiles.ManifestEntry{
+			Source:        e.Source,
+			SourceSize:    e.SourceSize,
+			SourceModTime: *e.SourceModTime,
+			Size:          e.Bytes,
+			ModTime:       *e.DestModTime,
+			Checksums:     e.Checksums,
+			CompletedAt:   e.Time,
+		})
+	}
+}
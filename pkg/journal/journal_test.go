@@ -0,0 +1,101 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fileops.FileMapping{
+		SourceBAM: filepath.Join(dir, "a.bam"), SourcePBI: filepath.Join(dir, "a.bam.pbi"),
+		DestBAM: filepath.Join(out, "A.bam"), DestPBI: filepath.Join(out, "A.bam.pbi"),
+		BioSample: "A",
+	}
+	for _, path := range []string{m.SourceBAM, m.DestBAM} {
+		if err := os.WriteFile(path, []byte("bam"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session := NewSessionID("r84001_20240101_000000", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if session != "20240102T030405Z_r84001_20240101_000000" {
+		t.Fatalf("unexpected session id %q", session)
+	}
+	j, err := Create(stateDir, session)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := j.Plan("r84001_20240101_000000", out, "sample S*", []*fileops.FileMapping{m}); err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	j.FileStarted(m, m.SourceBAM, m.DestBAM)
+	j.FileDone(m, &copyfiles.FileResult{Source: m.SourceBAM, Dest: m.DestBAM, Bytes: 3,
+		Checksums: copyfiles.Checksums{copyfiles.HashMD5: "abc"}}, false)
+	j.FileFailed(m, m.SourcePBI, m.DestPBI, errors.New("boom"))
+	// Simulate a crash: no end event, and a truncated trailing line.
+	j.file.WriteString(`{"type":"do`)
+	j.file.Close()
+
+	latest, err := Latest(stateDir)
+	if err != nil || latest != j.Path() {
+		t.Fatalf("Latest = %q, %v; want %q", latest, err, j.Path())
+	}
+
+	r, err := Read(latest)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if r.Run != "r84001_20240101_000000" || r.OutputDir != out || len(r.Mappings) != 1 || r.Filter != "sample S*" {
+		t.Fatalf("unexpected replay header: %+v", r)
+	}
+	if r.Finished {
+		t.Fatalf("interrupted session reported as finished")
+	}
+	if r.Pending() != 1 {
+		t.Fatalf("expected 1 pending file, got %d", r.Pending())
+	}
+	if _, ok := r.Failed[m.DestPBI]; !ok {
+		t.Fatalf("expected PBI failure to be replayed")
+	}
+
+	manifest, err := copyfiles.LoadManifest(copyfiles.ManifestPath(stateDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SeedManifest(manifest)
+	entry, ok := manifest.Lookup(m.DestBAM)
+	if !ok || entry.Size != 3 || entry.SourceSize != 3 || entry.Checksums[copyfiles.HashMD5] != "abc" {
+		t.Fatalf("manifest not seeded from journal: %+v, %v", entry, ok)
+	}
+
+	// Appending a resume and end event marks the session finished.
+	j, err = Append(latest)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	j.Resumed()
+	j.FileDone(m, &copyfiles.FileResult{Source: m.SourcePBI, Dest: m.DestPBI}, true)
+	if err := j.Close(0, 1, 0); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r, err = Read(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Finished || r.Pending() != 0 || len(r.Failed) != 0 {
+		t.Fatalf("expected finished session, got finished=%v pending=%d failed=%d", r.Finished, r.Pending(), len(r.Failed))
+	}
+}
@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CellInfo identifies the SMRT cell a collection was sequenced on.
+type CellInfo struct {
+	WellName       string // Well on the plate, e.g. A01
+	PlateNumber    int    // Plate (1 or 2 on Revio); 0 when unknown
+	MovieName      string // Movie context, e.g. m84001_250922_110000_s1
+	CellIndex      int    // Zero-based cell index reported by the instrument; -1 when unknown
+	CellBarcode    string // SMRT cell (CellPac) barcode
+	InstrumentID   string // Instrument serial, e.g. 84001
+	InstrumentName string // Instrument name as configured on the instrument
+}
+
+// cellDirPattern matches Revio cell directories such as 1_A01.
+var cellDirPattern = regexp.MustCompile(`^([0-9]+)_([A-Z][0-9]{2})$`)
+
+// parseCellDir returns the plate and well of a Revio cell directory name such as 1_A01.
+func parseCellDir(name string) (plate int, well string, ok bool) {
+	m := cellDirPattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, "", false
+	}
+	plate, _ = strconv.Atoi(m[1])
+	return plate, m[2], true
+}
+
+// parseCellInfo extracts the cell identity from a CollectionMetadata element.
+// Well and plate fall back to the Revio cell directory name (<plate>_<well>)
+// when they are not present in the XML.
+func parseCellInfo(cm *CollectionMetadata, filePath string) CellInfo {
+	cell := CellInfo{
+		WellName:       strings.TrimSpace(cm.WellSample.WellName),
+		MovieName:      cm.Context,
+		CellIndex:      -1,
+		CellBarcode:    cm.CellPac.Barcode,
+		InstrumentID:   cm.InstrumentID,
+		InstrumentName: cm.InstrumentName,
+	}
+
+	if n, err := strconv.Atoi(strings.TrimSpace(cm.WellSample.PlateNumber)); err == nil {
+		cell.PlateNumber = n
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(cm.CellIndex)); err == nil {
+		cell.CellIndex = n
+	}
+
+	// metadata files live in <run>/<plate>_<well>/metadata/<movie>.metadata.xml
+	if plate, well, ok := parseCellDir(filepath.Base(filepath.Dir(filepath.Dir(filePath)))); ok {
+		if cell.PlateNumber == 0 {
+			cell.PlateNumber = plate
+		}
+		if cell.WellName == "" {
+			cell.WellName = well
+		}
+	}
+
+	if cell.MovieName == "" {
+		cell.MovieName = strings.TrimSuffix(filepath.Base(filePath), ".metadata.xml")
+	}
+
+	return cell
+}
+
+// Position returns the plate/well position of the cell, e.g. 1_A01.
+func (c CellInfo) Position() string {
+	switch {
+	case c.WellName == "":
+		return ""
+	case c.PlateNumber == 0:
+		return c.WellName
+	default:
+		return fmt.Sprintf("%d_%s", c.PlateNumber, c.WellName)
+	}
+}
+
+// Instrument returns a human-readable instrument label.
+func (c CellInfo) Instrument() string {
+	switch {
+	case c.InstrumentName != "" && c.InstrumentID != "":
+		return fmt.Sprintf("%s (%s)", c.InstrumentName, c.InstrumentID)
+	case c.InstrumentName != "":
+		return c.InstrumentName
+	default:
+		return c.InstrumentID
+	}
+}
+
+// String returns a short label identifying the cell, e.g. 1_A01 m84001_250922_110000_s1.
+func (c CellInfo) String() string {
+	pos := c.Position()
+	if pos == "" {
+		return c.MovieName
+	}
+	if c.MovieName == "" {
+		return pos
+	}
+	return pos + " " + c.MovieName
+}
@@ -0,0 +1,158 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataSetFile represents the root element of a dataset XML file such as
+// <movie>.hifi_reads.bc2001--bc2001.consensusreadset.xml.
+type DataSetFile struct {
+	Name              string              `xml:"Name,attr"`
+	ExternalResources []ExternalResource  `xml:"ExternalResources>ExternalResource"`
+	DataSetMetadata   DataSetFileMetadata `xml:"DataSetMetadata"`
+}
+
+// ExternalResource represents an ExternalResource element (a BAM file and its indices).
+type ExternalResource struct {
+	MetaType    string      `xml:"MetaType,attr"`
+	ResourceID  string      `xml:"ResourceId,attr"`
+	FileIndices []FileIndex `xml:"FileIndices>FileIndex"`
+}
+
+// FileIndex represents a FileIndex element (e.g. the PacBio .pbi index).
+type FileIndex struct {
+	MetaType   string `xml:"MetaType,attr"`
+	ResourceID string `xml:"ResourceId,attr"`
+}
+
+// DataSetFileMetadata represents the summary counters of a dataset XML file.
+type DataSetFileMetadata struct {
+	TotalLength int64 `xml:"TotalLength"`
+	NumRecords  int64 `xml:"NumRecords"`
+}
+
+// DataSetInfo holds the information extracted from a dataset XML file.
+type DataSetInfo struct {
+	FilePath    string
+	Barcode     string // Barcode pair taken from the file name; empty for non-barcoded datasets
+	TotalLength int64  // Total number of bases
+	NumRecords  int64  // Number of reads
+	Resources   []ResourceInfo
+}
+
+// ResourceInfo is an external resource of a dataset with its path resolved.
+type ResourceInfo struct {
+	Path     string
+	MetaType string
+	Indices  []string // Resolved paths of the resource's index files
+}
+
+// IsBAM reports whether the resource is a BAM file.
+func (r ResourceInfo) IsBAM() bool {
+	return strings.HasSuffix(strings.ToLower(r.Path), ".bam")
+}
+
+// PBI returns the path of the PacBio index of the resource, or "" when none is listed.
+func (r ResourceInfo) PBI() string {
+	for _, idx := range r.Indices {
+		if strings.HasSuffix(strings.ToLower(idx), ".pbi") {
+			return idx
+		}
+	}
+	return ""
+}
+
+// ParseDataSetFile parses a dataset XML file.
+func ParseDataSetFile(filePath string) (*DataSetInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseDataSet(file, filePath)
+}
+
+// parseDataSet parses a dataset XML document from an io.Reader.
+func parseDataSet(r io.Reader, filePath string) (*DataSetInfo, error) {
+	var ds DataSetFile
+	if err := xml.NewDecoder(r).Decode(&ds); err != nil {
+		return nil, err
+	}
+	baseDir := filepath.Dir(filePath)
+	var resources []ResourceInfo
+	for _, er := range ds.ExternalResources {
+		res := ResourceInfo{Path: resolveResourcePath(baseDir, er.ResourceID), MetaType: er.MetaType}
+		for _, idx := range er.FileIndices {
+			res.Indices = append(res.Indices, resolveResourcePath(baseDir, idx.ResourceID))
+		}
+		resources = append(resources, res)
+	}
+	return &DataSetInfo{
+		FilePath:    filePath,
+		Barcode:     barcodeFromFileName(filepath.Base(filePath)),
+		TotalLength: ds.DataSetMetadata.TotalLength,
+		NumRecords:  ds.DataSetMetadata.NumRecords,
+		Resources:   resources,
+	}, nil
+}
+
+// resolveResourcePath turns a ResourceId into a filesystem path. Relative ids are
+// relative to the directory of the dataset XML file.
+func resolveResourcePath(baseDir, resourceID string) string {
+	p := strings.TrimPrefix(strings.TrimSpace(resourceID), "file://")
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, filepath.FromSlash(p))
+}
+
+// NormalizeBarcode returns a barcode in <fwd>--<rev> form. A single barcode, as
+// Revio writes symmetric pairs in file names (bc2001), is read as bc2001--bc2001.
+func NormalizeBarcode(barcode string) string {
+	if barcode == "" || strings.Contains(barcode, "--") {
+		return barcode
+	}
+	return barcode + "--" + barcode
+}
+
+// BarcodeFileNames returns the forms a barcode takes in Revio file names: the
+// <fwd>--<rev> pair and, for a symmetric pair, the single barcode.
+func BarcodeFileNames(barcode string) []string {
+	pair := NormalizeBarcode(barcode)
+	if pair == "" {
+		return nil
+	}
+	names := []string{pair}
+	if fwd, rev, _ := strings.Cut(pair, "--"); fwd == rev {
+		names = append(names, fwd)
+	}
+	return names
+}
+
+// notBarcodes are name parts that follow ".hifi_reads." in per-cell files.
+var notBarcodes = map[string]bool{"consensusreadset": true, "ccs_report": true, "unassigned": true}
+
+// barcodeFromFileName extracts the barcode pair of a per-barcode file name, e.g.
+// bc2001--bc2001 from <movie>.hifi_reads.bc2001.consensusreadset.xml or
+// <movie>.hifi_reads.bc2001--bc2001.ccs_report.json. It returns "" for per-cell files.
+func barcodeFromFileName(name string) string {
+	const marker = ".hifi_reads."
+	if i := strings.Index(name, marker); i >= 0 {
+		rest := name[i+len(marker):]
+		if j := strings.Index(rest, "."); j > 0 && !notBarcodes[rest[:j]] {
+			return NormalizeBarcode(rest[:j])
+		}
+		return ""
+	}
+	for _, part := range strings.Split(name, ".") {
+		if strings.Contains(part, "--") {
+			return part
+		}
+	}
+	return ""
+}
@@ -1,14 +1,16 @@
 package metadata
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 )
 
 // PacBioDataModel represents the root element of the metadata XML file.
@@ -24,7 +26,7 @@ type ExperimentContainer struct {
 
 // Runs represents the Runs element.
 type Runs struct {
-	Run Run `xml:"Run"`
+	Run []Run `xml:"Run"`
 }
 
 // Run represents the Run element.
@@ -33,14 +35,16 @@ type Run struct {
 	Outputs Outputs `xml:"Outputs"`
 }
 
-// Outputs represents the Outputs element.
+// Outputs represents the Outputs element. Older ICS versions write SubreadSets,
+// newer Revio versions write ConsensusReadSets; both are accepted.
 type Outputs struct {
-	SubreadSets SubreadSets `xml:"SubreadSets"`
+	SubreadSets       SubreadSets       `xml:"SubreadSets"`
+	ConsensusReadSets ConsensusReadSets `xml:"ConsensusReadSets"`
 }
 
 // SubreadSets represents the SubreadSets element.
 type SubreadSets struct {
-	SubreadSet SubreadSet `xml:"SubreadSet"`
+	SubreadSet []SubreadSet `xml:"SubreadSet"`
 }
 
 // SubreadSet represents the SubreadSet element.
@@ -48,6 +52,16 @@ type SubreadSet struct {
 	DataSetMetadata DataSetMetadata `xml:"DataSetMetadata"`
 }
 
+// ConsensusReadSets represents the ConsensusReadSets element.
+type ConsensusReadSets struct {
+	ConsensusReadSet []ConsensusReadSet `xml:"ConsensusReadSet"`
+}
+
+// ConsensusReadSet represents the ConsensusReadSet element.
+type ConsensusReadSet struct {
+	DataSetMetadata DataSetMetadata `xml:"DataSetMetadata"`
+}
+
 // DataSetMetadata represents the DataSetMetadata element.
 type DataSetMetadata struct {
 	Collections Collections `xml:"Collections"`
@@ -55,13 +69,23 @@ type DataSetMetadata struct {
 
 // Collections represents the Collections element.
 type Collections struct {
-	CollectionMetadata CollectionMetadata `xml:"CollectionMetadata"`
+	CollectionMetadata []CollectionMetadata `xml:"CollectionMetadata"`
 }
 
 // CollectionMetadata represents the CollectionMetadata element.
 type CollectionMetadata struct {
-	RunDetails RunDetails `xml:"RunDetails"`
-	WellSample WellSample `xml:"WellSample"`
+	Context        string     `xml:"Context,attr"`
+	InstrumentID   string     `xml:"InstrumentId,attr"`
+	InstrumentName string     `xml:"InstrumentName,attr"`
+	RunDetails     RunDetails `xml:"RunDetails"`
+	WellSample     WellSample `xml:"WellSample"`
+	CellPac        CellPac    `xml:"CellPac"`
+	CellIndex      string     `xml:"CellIndex"`
+}
+
+// CellPac represents the CellPac element (the SMRT cell consumable).
+type CellPac struct {
+	Barcode string `xml:"Barcode,attr"`
 }
 
 // RunDetails represents the RunDetails element.
@@ -75,8 +99,10 @@ type RunDetails struct {
 
 // WellSample represents the WellSample element.
 type WellSample struct {
-	Name       string      `xml:"Name,attr"`
-	BioSamples []BioSample `xml:"BioSamples>BioSample"`
+	Name        string      `xml:"Name,attr"`
+	WellName    string      `xml:"WellName"`
+	PlateNumber string      `xml:"PlateNumber"`
+	BioSamples  []BioSample `xml:"BioSamples>BioSample"`
 }
 
 // BioSample represents the BioSample element.
@@ -111,15 +137,19 @@ type MetadataInfo struct {
 	RunName        string
 	BioSamples     []BioSampleInfo // Changed to a slice of BioSampleInfo
 	FilePath       string
-	CreatedDate    string
-	StartedDate    string
+	CreatedDate    time.Time // Zero when unknown
+	StartedDate    time.Time // Zero when unknown
 	IsMultiplex    bool
 	WellSampleName string
+	Cell           CellInfo
+	Stats          *CellStats // QC statistics; nil when no statistics files were found
 	Status         RunStatus
+	Warnings       []string // Non-fatal problems found while parsing
 }
 
 // ParseMetadataFile parses a metadata XML file and extracts run + biosample information.
-func ParseMetadataFile(filePath string) (*MetadataInfo, error) {
+// One MetadataInfo is returned per CollectionMetadata found in the file.
+func ParseMetadataFile(filePath string) ([]*MetadataInfo, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -130,23 +160,87 @@ func ParseMetadataFile(filePath string) (*MetadataInfo, error) {
 }
 
 // ParseMetadataFromReader parses metadata from an io.Reader (exported for testing).
-func ParseMetadataFromReader(r io.Reader, filePath string) (*MetadataInfo, error) {
+func ParseMetadataFromReader(r io.Reader, filePath string) ([]*MetadataInfo, error) {
 	return parseMetadata(r, filePath)
 }
 
+// collectionRef ties a CollectionMetadata element to the Run it was found under.
+type collectionRef struct {
+	run        *Run
+	collection *CollectionMetadata
+}
+
+// collections walks every dataset (SubreadSet and ConsensusReadSet) of every Run
+// and returns all CollectionMetadata elements in document order.
+func (m *PacBioDataModel) collections() []collectionRef {
+	var refs []collectionRef
+	runs := m.ExperimentContainer.Runs.Run
+	for i := range runs {
+		run := &runs[i]
+		var datasets []*DataSetMetadata
+		for j := range run.Outputs.SubreadSets.SubreadSet {
+			datasets = append(datasets, &run.Outputs.SubreadSets.SubreadSet[j].DataSetMetadata)
+		}
+		for j := range run.Outputs.ConsensusReadSets.ConsensusReadSet {
+			datasets = append(datasets, &run.Outputs.ConsensusReadSets.ConsensusReadSet[j].DataSetMetadata)
+		}
+		for _, ds := range datasets {
+			for k := range ds.Collections.CollectionMetadata {
+				refs = append(refs, collectionRef{run: run, collection: &ds.Collections.CollectionMetadata[k]})
+			}
+		}
+	}
+	return refs
+}
+
 // parseMetadata parses metadata from an io.Reader
-func parseMetadata(r io.Reader, filePath string) (*MetadataInfo, error) {
+func parseMetadata(r io.Reader, filePath string) ([]*MetadataInfo, error) {
 	var model PacBioDataModel
 	decoder := xml.NewDecoder(r)
 	if err := decoder.Decode(&model); err != nil {
 		return nil, err
 	}
 
-	// Extract run details
-	collectionMetadata := model.ExperimentContainer.Runs.Run.Outputs.SubreadSets.SubreadSet.DataSetMetadata.Collections.CollectionMetadata
+	refs := model.collections()
+	if len(refs) == 0 {
+		return nil, errors.New("no collection metadata found")
+	}
+
+	var infos []*MetadataInfo
+	var errs []error
+	seen := make(map[string]bool)
+	for i, ref := range refs {
+		// The same collection is often listed under both the SubreadSet and the
+		// ConsensusReadSet; the movie context identifies it uniquely.
+		if ctx := ref.collection.Context; ctx != "" {
+			if seen[ctx] {
+				continue
+			}
+			seen[ctx] = true
+		}
+
+		info, err := parseCollection(ref.run, ref.collection, filePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collection %d: %w", i+1, err))
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	if len(infos) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return infos, nil
+}
+
+// parseCollection extracts run + biosample information from a single CollectionMetadata.
+func parseCollection(run *Run, collectionMetadata *CollectionMetadata, filePath string) (*MetadataInfo, error) {
 	runDetails := collectionMetadata.RunDetails
 
 	runName := runDetails.Name
+	if runName == "" {
+		runName = run.Name
+	}
 	if runName == "" {
 		return nil, errors.New("run name not found in metadata")
 	}
@@ -171,8 +265,15 @@ func parseMetadata(r io.Reader, filePath string) (*MetadataInfo, error) {
 	isMultiplex := len(bioSampleInfos) > 1 && bioSampleInfos[0].Barcode != ""
 
 	// Extract dates
-	createdDate := runDetails.WhenCreated
-	startedDate := runDetails.WhenStarted
+	var warnings []string
+	createdDate, err := ParseTimestamp(runDetails.WhenCreated)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("WhenCreated: %v", err))
+	}
+	startedDate, err := ParseTimestamp(runDetails.WhenStarted)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("WhenStarted: %v", err))
+	}
 
 	return &MetadataInfo{
 		RunName:        runName,
@@ -182,17 +283,23 @@ func parseMetadata(r io.Reader, filePath string) (*MetadataInfo, error) {
 		StartedDate:    startedDate,
 		IsMultiplex:    isMultiplex,
 		WellSampleName: collectionMetadata.WellSample.Name,
+		Cell:           parseCellInfo(collectionMetadata, filePath),
 		Status:         RunComplete,
+		Warnings:       warnings,
 	}, nil
 }
 
 // FindMetadataFiles finds all metadata XML files under root (excluding previews).
-func FindMetadataFiles(rootDir string) ([]string, error) {
+// The walk stops with ctx.Err() when ctx is cancelled.
+func FindMetadataFiles(ctx context.Context, rootDir string) ([]string, error) {
 	var files []string
 	err := filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil { // Propagate filesystem errors.
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !d.IsDir() {
 			return nil
 		}
@@ -219,13 +326,16 @@ func FindMetadataFiles(rootDir string) ([]string, error) {
 }
 
 // FindPendingRuns finds runs that have started transferring but are not yet complete.
-func FindPendingRuns(rootDir string) (map[string]*RunInfo, error) {
+func FindPendingRuns(ctx context.Context, rootDir string) (map[string]*RunInfo, error) {
 	pendingRuns := make(map[string]*RunInfo)
 
 	err := filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() || !strings.HasPrefix(d.Name(), "Transfer_Test_") || !strings.HasSuffix(d.Name(), ".txt") {
 			return nil
 		}
@@ -259,26 +369,14 @@ func FindPendingRuns(rootDir string) (map[string]*RunInfo, error) {
 		// Create a new RunInfo if it's the first time we see this run
 		if _, exists := pendingRuns[runName]; !exists {
 			// Try to infer date from run name (e.g., r84297_20250922_085610)
-			var startedDate string
-			nameParts := strings.Split(runName, "_")
-			if len(nameParts) >= 2 {
-				dateStr := nameParts[1]
-				if len(dateStr) == 8 {
-					// Basic validation for YYYYMMDD
-					year, errYear := strconv.Atoi(dateStr[0:4])
-					month, errMonth := strconv.Atoi(dateStr[4:6])
-					day, errDay := strconv.Atoi(dateStr[6:8])
-					if errYear == nil && errMonth == nil && errDay == nil && year > 2000 && month > 0 && month <= 12 && day > 0 && day <= 31 {
-						startedDate = dateStr
-					}
-				}
-			}
+			startedDate, _ := InferDateFromRunName(runName)
 
 			pendingRuns[runName] = &RunInfo{
-				Name:        runName,
-				Status:      RunPending,
-				Cells:       []*MetadataInfo{},
-				StartedDate: startedDate,
+				Name:         runName,
+				Status:       RunPending,
+				Cells:        []*MetadataInfo{},
+				StartedDate:  startedDate,
+				DateInferred: !startedDate.IsZero(),
 			}
 		}
 
@@ -292,9 +390,41 @@ func FindPendingRuns(rootDir string) (map[string]*RunInfo, error) {
 	return pendingRuns, nil
 }
 
+// FindPendingCells returns the cells of a run directory whose transfer has not
+// finished: their metadata directory holds a Transfer_Test_*.txt marker but no
+// metadata XML yet. FilePath points at the marker, Status is RunPending and the
+// cell identity is taken from the cell directory name.
+func FindPendingCells(runDir string) ([]*MetadataInfo, error) {
+	entries, err := os.ReadDir(runDir)
+	if err != nil {
+		return nil, err
+	}
+	var cells []*MetadataInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		metadataDir := filepath.Join(runDir, e.Name(), "metadata")
+		markers, _ := filepath.Glob(filepath.Join(metadataDir, "Transfer_Test_*.txt"))
+		xmls, _ := filepath.Glob(filepath.Join(metadataDir, "*.metadata.xml"))
+		if len(markers) == 0 || len(xmls) > 0 {
+			continue
+		}
+		cell := CellInfo{CellIndex: -1}
+		cell.PlateNumber, cell.WellName, _ = parseCellDir(e.Name())
+		cells = append(cells, &MetadataInfo{
+			RunName:  filepath.Base(runDir),
+			FilePath: markers[0],
+			Cell:     cell,
+			Status:   RunPending,
+		})
+	}
+	return cells, nil
+}
+
 // FindRunsByName aggregates all metadata cells for a specific run name.
-func FindRunsByName(rootDir string, runName string) (*RunInfo, error) {
-	allRuns, err := GetAllRuns(rootDir)
+func FindRunsByName(ctx context.Context, rootDir string, runName string) (*RunInfo, error) {
+	allRuns, err := GetAllRuns(ctx, rootDir)
 	if err != nil {
 		return nil, err
 	}
@@ -314,8 +444,9 @@ func FindRunsByName(rootDir string, runName string) (*RunInfo, error) {
 // RunInfo contains aggregated information about a run.
 type RunInfo struct {
 	Name           string
-	CreatedDate    string
-	StartedDate    string
+	CreatedDate    time.Time // Earliest creation time across cells; zero when unknown
+	StartedDate    time.Time // Earliest start time across cells; zero when unknown
+	DateInferred   bool      // StartedDate was derived from the run name rather than metadata
 	Cells          []*MetadataInfo
 	BioSampleNames map[string]bool // Used as a set to track unique biosamples
 	Status         RunStatus
@@ -326,10 +457,83 @@ func (r *RunInfo) BioSampleCount() int {
 	return len(r.BioSampleNames)
 }
 
+// Stats returns the HiFi statistics summed over all cells of the run.
+func (r *RunInfo) Stats() ReadStats {
+	var total ReadStats
+	for _, cell := range r.Cells {
+		if cell.Stats != nil {
+			total = total.Add(cell.Stats.Cell)
+		}
+	}
+	return total
+}
+
+// BioSampleStats returns the HiFi statistics of a biosample summed over all cells
+// it was sequenced on. The boolean is false when no cell reported statistics for it.
+func (r *RunInfo) BioSampleStats(name string) (ReadStats, bool) {
+	var total ReadStats
+	found := false
+	for _, cell := range r.Cells {
+		for _, bs := range cell.BioSamples {
+			if bs.Name != name {
+				continue
+			}
+			if s, ok := cell.Stats.ForBarcode(bs.Barcode); ok {
+				total = total.Add(s)
+				found = true
+			}
+		}
+	}
+	return total, found
+}
+
+// CellDir returns the cell directory of a metadata file (<run>/<cell>/metadata/<movie>.metadata.xml).
+func (m *MetadataInfo) CellDir() string {
+	return filepath.Dir(filepath.Dir(m.FilePath))
+}
+
+// DataSize returns the total size of the files in the run's cell directories.
+// Pending runs have no parsed cells and report 0.
+func (r *RunInfo) DataSize(ctx context.Context) (int64, error) {
+	var total int64
+	seen := make(map[string]bool)
+	for _, cell := range r.Cells {
+		dir := cell.CellDir()
+		if seen[dir] {
+			continue // Several collections can share one metadata file
+		}
+		seen[dir] = true
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
 // GetAllRuns parses and aggregates metadata for all available runs.
-func GetAllRuns(rootDir string) ([]*RunInfo, error) {
+// It returns ctx.Err() if ctx is cancelled before the scan completes.
+func GetAllRuns(ctx context.Context, rootDir string) ([]*RunInfo, error) {
 	// Find all completed runs first
-	metadataFiles, err := FindMetadataFiles(rootDir)
+	metadataFiles, err := FindMetadataFiles(ctx, rootDir)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		// We can proceed without completed runs, as there might be pending ones.
 	}
@@ -337,34 +541,56 @@ func GetAllRuns(rootDir string) ([]*RunInfo, error) {
 	runsMap := make(map[string]*RunInfo)
 
 	for _, file := range metadataFiles {
-		info, err := ParseMetadataFile(file)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		infos, err := ParseMetadataFile(file)
 		if err != nil {
 			continue // Skip files that can't be parsed
 		}
 
-		// Get or create run info
-		runInfo, exists := runsMap[info.RunName]
-		if !exists {
-			runInfo = &RunInfo{
-				Name:           info.RunName,
-				CreatedDate:    info.CreatedDate,
-				StartedDate:    info.StartedDate,
-				Cells:          []*MetadataInfo{},
-				BioSampleNames: make(map[string]bool),
-				Status:         RunComplete,
+		for _, info := range infos {
+			stats, err := LoadCellStats(info.FilePath, info.Cell.MovieName)
+			if err != nil {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("statistics: %v", err))
+			}
+			info.Stats = stats
+
+			// Get or create run info
+			runInfo, exists := runsMap[info.RunName]
+			if !exists {
+				runInfo = &RunInfo{
+					Name:           info.RunName,
+					CreatedDate:    info.CreatedDate,
+					StartedDate:    info.StartedDate,
+					Cells:          []*MetadataInfo{},
+					BioSampleNames: make(map[string]bool),
+					Status:         RunComplete,
+				}
+				runsMap[info.RunName] = runInfo
 			}
-			runsMap[info.RunName] = runInfo
-		}
 
-		// Add cell info and track unique biosamples
-		runInfo.Cells = append(runInfo.Cells, info)
-		for _, bs := range info.BioSamples {
-			runInfo.BioSampleNames[bs.Name] = true
+			// A run starts with its first cell
+			if earlier(info.CreatedDate, runInfo.CreatedDate) {
+				runInfo.CreatedDate = info.CreatedDate
+			}
+			if earlier(info.StartedDate, runInfo.StartedDate) {
+				runInfo.StartedDate = info.StartedDate
+			}
+
+			// Add cell info and track unique biosamples
+			runInfo.Cells = append(runInfo.Cells, info)
+			for _, bs := range info.BioSamples {
+				runInfo.BioSampleNames[bs.Name] = true
+			}
 		}
 	}
 
 	// Find and merge pending runs
-	pendingRuns, err := FindPendingRuns(rootDir)
+	pendingRuns, err := FindPendingRuns(ctx, rootDir)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		// Log or handle error, but don't abort if we have complete runs
 	}
@@ -391,15 +617,53 @@ func GetAllRuns(rootDir string) ([]*RunInfo, error) {
 	return runs, nil
 }
 
-// sortRunsByDate sorts runs by their started date, newest first
+// earlier reports whether t is known and before current (or current is unknown).
+func earlier(t, current time.Time) bool {
+	return !t.IsZero() && (current.IsZero() || t.Before(current))
+}
+
+// Date returns the best known date of the run: started, created, or inferred from its name.
+// It is zero when none is known.
+func (r *RunInfo) Date() time.Time { return runSortDate(r) }
+
+// runSortDate returns the date used for ordering a run, falling back to the run name.
+func runSortDate(run *RunInfo) time.Time {
+	if !run.StartedDate.IsZero() {
+		return run.StartedDate
+	}
+	if !run.CreatedDate.IsZero() {
+		return run.CreatedDate
+	}
+	t, _ := InferDateFromRunName(run.Name)
+	return t
+}
+
+// sortRunsByDate sorts runs by their started date, newest first.
+// Runs without any known date come last; ties are broken by name so the order is total.
 func sortRunsByDate(runs []*RunInfo) {
-	// Sort runs by date (newest first)
-	sort.Slice(runs, func(i, j int) bool {
-		// If we have started dates, use them
-		if runs[i].StartedDate != "" && runs[j].StartedDate != "" {
-			return runs[i].StartedDate > runs[j].StartedDate
+	sort.SliceStable(runs, func(i, j int) bool {
+		di, dj := runSortDate(runs[i]), runSortDate(runs[j])
+		if di.IsZero() != dj.IsZero() {
+			return !di.IsZero()
+		}
+		if !di.Equal(dj) {
+			return di.After(dj)
 		}
-		// If no started dates available, sort by name
 		return runs[i].Name > runs[j].Name
 	})
 }
+
+// SortRunsOldestFirst sorts runs by their date, oldest first. Runs without any
+// known date come last; ties are broken by name.
+func SortRunsOldestFirst(runs []*RunInfo) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		di, dj := runSortDate(runs[i]), runSortDate(runs[j])
+		if di.IsZero() != dj.IsZero() {
+			return !di.IsZero()
+		}
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return runs[i].Name < runs[j].Name
+	})
+}
@@ -1,8 +1,12 @@
 package metadata
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 const sampleXML = `<?xml version="1.0" encoding="utf-8"?>
@@ -44,10 +48,14 @@ const sampleXML = `<?xml version="1.0" encoding="utf-8"?>
 </PacBioDataModel>`
 
 func TestParseMetadataFromReader(t *testing.T) {
-	info, err := ParseMetadataFromReader(strings.NewReader(sampleXML), "in-memory.xml")
+	infos, err := ParseMetadataFromReader(strings.NewReader(sampleXML), "in-memory.xml")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(infos) != 1 {
+		t.Fatalf("expected one collection got %d", len(infos))
+	}
+	info := infos[0]
 	if info.RunName != "RUN123" {
 		t.Fatalf("expected run name RUN123 got %s", info.RunName)
 	}
@@ -61,3 +69,274 @@ func TestParseMetadataFromReader(t *testing.T) {
 		t.Fatalf("unexpected well sample name %s", info.WellSampleName)
 	}
 }
+
+const multiCollectionXML = `<?xml version="1.0" encoding="utf-8"?>
+<PacBioDataModel>
+  <ExperimentContainer>
+    <Runs>
+      <Run Name="RUN456">
+        <Outputs>
+          <SubreadSets>
+            <SubreadSet>
+              <DataSetMetadata>
+                <Collections>
+                  <CollectionMetadata Context="m84001_250922_110000_s1">
+                    <RunDetails><Name>RUN456</Name></RunDetails>
+                    <WellSample Name="WS1">
+                      <BioSamples><BioSample Name="SAMPLE_A" /></BioSamples>
+                    </WellSample>
+                  </CollectionMetadata>
+                </Collections>
+              </DataSetMetadata>
+            </SubreadSet>
+          </SubreadSets>
+          <ConsensusReadSets>
+            <ConsensusReadSet>
+              <DataSetMetadata>
+                <Collections>
+                  <CollectionMetadata Context="m84001_250922_110000_s1">
+                    <RunDetails><Name>RUN456</Name></RunDetails>
+                    <WellSample Name="WS1">
+                      <BioSamples><BioSample Name="SAMPLE_A" /></BioSamples>
+                    </WellSample>
+                  </CollectionMetadata>
+                  <CollectionMetadata Context="m84001_250922_120000_s2">
+                    <WellSample Name="WS2">
+                      <BioSamples>
+                        <BioSample Name="SAMPLE_B">
+                          <DNABarcodes><DNABarcode Name="bc2001--bc2001" /></DNABarcodes>
+                        </BioSample>
+                        <BioSample Name="SAMPLE_C">
+                          <DNABarcodes><DNABarcode Name="bc2002--bc2002" /></DNABarcodes>
+                        </BioSample>
+                      </BioSamples>
+                    </WellSample>
+                  </CollectionMetadata>
+                </Collections>
+              </DataSetMetadata>
+            </ConsensusReadSet>
+          </ConsensusReadSets>
+        </Outputs>
+      </Run>
+    </Runs>
+  </ExperimentContainer>
+</PacBioDataModel>`
+
+func TestParseMetadataMultipleCollections(t *testing.T) {
+	infos, err := ParseMetadataFromReader(strings.NewReader(multiCollectionXML), "in-memory.xml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected two collections (duplicate context skipped) got %d", len(infos))
+	}
+	if infos[1].RunName != "RUN456" {
+		t.Fatalf("expected run name from Run attribute got %q", infos[1].RunName)
+	}
+	if !infos[1].IsMultiplex || len(infos[1].BioSamples) != 2 {
+		t.Fatalf("expected multiplexed second collection got %+v", infos[1])
+	}
+}
+
+func TestParseMetadataNoCollections(t *testing.T) {
+	const emptyXML = `<PacBioDataModel><ExperimentContainer><Runs><Run Name="R"/></Runs></ExperimentContainer></PacBioDataModel>`
+	if _, err := ParseMetadataFromReader(strings.NewReader(emptyXML), "in-memory.xml"); err == nil {
+		t.Fatal("expected error for metadata without collections")
+	}
+}
+
+func TestParseCellInfo(t *testing.T) {
+	const cellXML = `<PacBioDataModel><ExperimentContainer><Runs><Run Name="RUN789"><Outputs><ConsensusReadSets><ConsensusReadSet><DataSetMetadata><Collections>
+<CollectionMetadata Context="m84001_250922_110000_s2" InstrumentId="84001" InstrumentName="Revio1">
+  <RunDetails><Name>RUN789</Name></RunDetails>
+  <WellSample Name="WS1"><WellName>B01</WellName><BioSamples><BioSample Name="S1" /></BioSamples></WellSample>
+  <CellPac Barcode="EA123456" />
+  <CellIndex>1</CellIndex>
+</CollectionMetadata>
+</Collections></DataSetMetadata></ConsensusReadSet></ConsensusReadSets></Outputs></Run></Runs></ExperimentContainer></PacBioDataModel>`
+
+	infos, err := ParseMetadataFromReader(strings.NewReader(cellXML), "/runs/RUN789/2_B01/metadata/m84001_250922_110000_s2.metadata.xml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cell := infos[0].Cell
+	if cell.WellName != "B01" || cell.PlateNumber != 2 || cell.CellIndex != 1 {
+		t.Fatalf("unexpected cell position %+v", cell)
+	}
+	if cell.MovieName != "m84001_250922_110000_s2" || cell.CellBarcode != "EA123456" {
+		t.Fatalf("unexpected cell identity %+v", cell)
+	}
+	if cell.Instrument() != "Revio1 (84001)" {
+		t.Fatalf("unexpected instrument label %q", cell.Instrument())
+	}
+	if cell.Position() != "2_B01" {
+		t.Fatalf("unexpected position %q", cell.Position())
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	ts, err := ParseTimestamp("2025-09-22T11:00:00+02:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ts.Equal(time.Date(2025, 9, 22, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected time %v", ts)
+	}
+	if ts, err := ParseTimestamp(""); err != nil || !ts.IsZero() {
+		t.Fatalf("expected zero time for empty value got %v %v", ts, err)
+	}
+	if _, err := ParseTimestamp("yesterday"); err == nil {
+		t.Fatal("expected error for invalid timestamp")
+	}
+}
+
+func TestInferDateFromRunName(t *testing.T) {
+	ts, ok := InferDateFromRunName("r84297_20250922_085610")
+	if !ok || ts.Year() != 2025 || ts.Month() != 9 || ts.Day() != 22 || ts.Hour() != 8 {
+		t.Fatalf("unexpected inferred date %v %v", ts, ok)
+	}
+	if _, ok := InferDateFromRunName("RUN123"); ok {
+		t.Fatal("expected no date for non-Revio run name")
+	}
+}
+
+func TestSortRunsByDate(t *testing.T) {
+	runs := []*RunInfo{
+		{Name: "no-date-a"},
+		{Name: "r84297_20250101_000000", Status: RunPending},
+		{Name: "complete", StartedDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "no-date-b"},
+	}
+	sortRunsByDate(runs)
+	want := []string{"complete", "r84297_20250101_000000", "no-date-b", "no-date-a"}
+	for i, name := range want {
+		if runs[i].Name != name {
+			t.Fatalf("position %d: expected %s got %s", i, name, runs[i].Name)
+		}
+	}
+}
+
+func TestSortRunsOldestFirst(t *testing.T) {
+	runs := []*RunInfo{
+		{Name: "no-date"},
+		{Name: "r84297_20250301_000000"},
+		{Name: "complete", StartedDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "r84297_20250101_000000"},
+	}
+	SortRunsOldestFirst(runs)
+	want := []string{"r84297_20250101_000000", "r84297_20250301_000000", "complete", "no-date"}
+	for i, name := range want {
+		if runs[i].Name != name {
+			t.Fatalf("position %d: expected %s got %s", i, name, runs[i].Name)
+		}
+	}
+}
+
+func TestLoadCellStats(t *testing.T) {
+	cellDir := filepath.Join(t.TempDir(), "r84001_20250922_100000", "1_A01")
+	movie := "m84001_250922_110000_s1"
+	for _, dir := range []string{"metadata", "statistics", "hifi_reads"} {
+		if err := os.MkdirAll(filepath.Join(cellDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(cellDir, rel), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("statistics/"+movie+".ccs_report.json", `{"attributes":[
+		{"id":"ccs2.number_of_ccs_reads","value":2000},
+		{"id":"ccs2.total_number_of_ccs_bases","value":30000000},
+		{"id":"ccs2.ccs_readlength_n50","value":16000},
+		{"id":"ccs2.median_ccs_readlength","value":"NA"}]}`)
+	writeFile("statistics/"+movie+".hifi_reads.bc2002.ccs_report.json", `{"attributes":[
+		{"id":"ccs2.number_of_ccs_reads","value":50},
+		{"id":"ccs2.median_ccs_readlength","value":14000},
+		{"id":"ccs2.ccs_readlength_n50","value":17000},
+		{"id":"ccs2.mean_ccs_readquality","value":32.5}]}`)
+	writeFile("hifi_reads/"+movie+".hifi_reads.bc2002.consensusreadset.xml",
+		`<ConsensusReadSet><DataSetMetadata><TotalLength>750000</TotalLength><NumRecords>50</NumRecords></DataSetMetadata></ConsensusReadSet>`)
+	writeFile("statistics/"+movie+".sts.xml", `<PipeStats><NumSequencingZmws>25000000</NumSequencingZmws></PipeStats>`)
+	writeFile("hifi_reads/"+movie+".hifi_reads.bc2001--bc2001.consensusreadset.xml",
+		`<ConsensusReadSet><DataSetMetadata><TotalLength>1500000</TotalLength><NumRecords>100</NumRecords></DataSetMetadata></ConsensusReadSet>`)
+
+	stats, err := LoadCellStats(filepath.Join(cellDir, "metadata", movie+".metadata.xml"), movie)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Cell.ReadCount != 2000 || stats.Cell.HiFiYield != 30000000 || stats.Cell.N50 != 16000 || stats.Cell.MedianReadLength != 0 {
+		t.Fatalf("unexpected cell stats %+v", stats.Cell)
+	}
+	if stats.ZMWs != 25000000 {
+		t.Fatalf("unexpected ZMW count %d", stats.ZMWs)
+	}
+	bc, ok := stats.ForBarcode("bc2001--bc2001")
+	if !ok || bc.ReadCount != 100 || bc.MeanReadLength != 15000 {
+		t.Fatalf("unexpected barcode stats %+v", bc)
+	}
+	// Symmetric barcodes are written with a single barcode in file names; the
+	// per-barcode report is completed with the yield of the dataset XML
+	bc, ok = stats.ForBarcode("bc2002--bc2002")
+	want := ReadStats{HiFiYield: 750000, ReadCount: 50, MeanReadLength: 15000, MedianReadLength: 14000, N50: 17000, MeanQuality: 32.5}
+	if !ok || bc != want {
+		t.Fatalf("unexpected barcode stats %+v", bc)
+	}
+
+	// Quality is weighted by read count; median and N50 cannot be combined
+	sum := bc.Add(ReadStats{HiFiYield: 150000, ReadCount: 10, MedianReadLength: 15000, MeanQuality: 20.5})
+	if sum.ReadCount != 60 || sum.MeanQuality != 30.5 || sum.MedianReadLength != 0 || sum.N50 != 0 {
+		t.Fatalf("unexpected sum %+v", sum)
+	}
+
+	if stats, err := LoadCellStats(filepath.Join(t.TempDir(), "x", "metadata", "m.metadata.xml"), ""); stats != nil || err != nil {
+		t.Fatalf("expected no stats without statistics files got %+v %v", stats, err)
+	}
+}
+
+func TestRunDataSize(t *testing.T) {
+	cellDir := filepath.Join(t.TempDir(), "r84001_20250922_100000", "1_A01")
+	for rel, size := range map[string]int{"metadata/m.metadata.xml": 10, "hifi_reads/m.hifi_reads.bam": 1000, "statistics/m.sts.xml": 5} {
+		path := filepath.Join(cellDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataPath := filepath.Join(cellDir, "metadata", "m.metadata.xml")
+	// Two collections of one metadata file are counted once
+	run := &RunInfo{Cells: []*MetadataInfo{{FilePath: metadataPath}, {FilePath: metadataPath}}}
+	size, err := run.DataSize(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size != 1015 {
+		t.Fatalf("expected 1015 bytes got %d", size)
+	}
+}
+
+func TestFindPendingCells(t *testing.T) {
+	runDir := filepath.Join(t.TempDir(), "r84001_20250922_100000")
+	for _, rel := range []string{
+		"1_A01/metadata/m84001_250922_110000_s1.metadata.xml",
+		"1_A01/metadata/Transfer_Test_1.txt",
+		"1_B01/metadata/Transfer_Test_2.txt",
+	} {
+		path := filepath.Join(runDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cells, err := FindPendingCells(runDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cells) != 1 || cells[0].Status != RunPending || cells[0].Cell.Position() != "1_B01" {
+		t.Fatalf("expected pending cell 1_B01 got %+v", cells)
+	}
+}
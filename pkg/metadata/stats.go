@@ -0,0 +1,237 @@
+package metadata
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadStats holds HiFi read QC statistics. Zero values mean "not reported".
+type ReadStats struct {
+	HiFiYield        int64   // Total HiFi bases
+	ReadCount        int64   // Number of HiFi reads
+	MeanReadLength   int64   // Mean HiFi read length in bases
+	MedianReadLength int64   // Median HiFi read length in bases
+	N50              int64   // HiFi read length N50 in bases
+	MeanQuality      float64 // Mean read quality (Phred QV)
+}
+
+// IsZero reports whether no statistic is set.
+func (s ReadStats) IsZero() bool { return s == ReadStats{} }
+
+// Add accumulates counts from other. The mean quality is weighted by read
+// count when both sides report it; median and N50 cannot be combined without
+// the underlying distributions and are kept only when one side is empty.
+func (s ReadStats) Add(other ReadStats) ReadStats {
+	if s.IsZero() {
+		return other
+	}
+	if other.IsZero() {
+		return s
+	}
+	sum := ReadStats{
+		HiFiYield: s.HiFiYield + other.HiFiYield,
+		ReadCount: s.ReadCount + other.ReadCount,
+	}
+	if sum.ReadCount > 0 {
+		sum.MeanReadLength = sum.HiFiYield / sum.ReadCount
+	}
+	if s.MeanQuality > 0 && other.MeanQuality > 0 && sum.ReadCount > 0 {
+		sum.MeanQuality = (s.MeanQuality*float64(s.ReadCount) + other.MeanQuality*float64(other.ReadCount)) /
+			float64(sum.ReadCount)
+	}
+	return sum
+}
+
+// merge fills the statistics missing from s with those of other.
+func (s ReadStats) merge(other ReadStats) ReadStats {
+	if s.HiFiYield == 0 {
+		s.HiFiYield = other.HiFiYield
+	}
+	if s.ReadCount == 0 {
+		s.ReadCount = other.ReadCount
+	}
+	if s.MeanReadLength == 0 {
+		s.MeanReadLength = other.MeanReadLength
+	}
+	if s.MedianReadLength == 0 {
+		s.MedianReadLength = other.MedianReadLength
+	}
+	if s.N50 == 0 {
+		s.N50 = other.N50
+	}
+	if s.MeanQuality == 0 {
+		s.MeanQuality = other.MeanQuality
+	}
+	return s
+}
+
+// CellStats holds the QC statistics of one SMRT cell.
+type CellStats struct {
+	Cell     ReadStats            // Whole-cell HiFi statistics
+	Barcodes map[string]ReadStats // Per barcode pair, e.g. bc2001--bc2001
+	ZMWs     int64                // Number of sequencing ZMWs (from sts.xml)
+	Sources  []string             // Files the statistics were read from
+}
+
+// ForBarcode returns the statistics for a barcode pair, or the cell-level
+// statistics when barcode is empty (non-multiplexed cell).
+func (c *CellStats) ForBarcode(barcode string) (ReadStats, bool) {
+	if c == nil {
+		return ReadStats{}, false
+	}
+	if barcode == "" {
+		return c.Cell, !c.Cell.IsZero()
+	}
+	s, ok := c.Barcodes[NormalizeBarcode(barcode)]
+	return s, ok
+}
+
+// LoadCellStats reads the QC statistics files that sit next to a metadata XML file:
+// <cell>/statistics/<movie>*ccs_report.json and <movie>.sts.xml for cell-level numbers,
+// and per barcode <cell>/statistics/<movie>.hifi_reads.<barcode>.ccs_report.json for the full
+// statistics, completed by <cell>/hifi_reads/<movie>.hifi_reads.<barcode>.consensusreadset.xml
+// (yield and read count only). It returns nil and no error when no statistics files exist.
+func LoadCellStats(metadataPath, movieName string) (*CellStats, error) {
+	cellDir := filepath.Dir(filepath.Dir(metadataPath))
+	statsDir := filepath.Join(cellDir, "statistics")
+	hifiDir := filepath.Join(cellDir, "hifi_reads")
+	if movieName == "" {
+		movieName = strings.TrimSuffix(filepath.Base(metadataPath), ".metadata.xml")
+	}
+
+	stats := &CellStats{Barcodes: make(map[string]ReadStats)}
+	var errs []error
+
+	reports, _ := filepath.Glob(filepath.Join(statsDir, movieName+"*ccs_report.json"))
+	cellReport := false
+	for _, report := range reports {
+		barcode := barcodeFromFileName(filepath.Base(report))
+		if barcode == "" && cellReport {
+			continue
+		}
+		s, err := parseCCSReport(report)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(report), err))
+			continue
+		}
+		if barcode == "" {
+			stats.Cell = s
+			cellReport = true
+		} else {
+			stats.Barcodes[barcode] = s
+		}
+		stats.Sources = append(stats.Sources, report)
+	}
+
+	stsPath := filepath.Join(statsDir, movieName+".sts.xml")
+	if _, err := os.Stat(stsPath); err == nil {
+		zmws, err := parseStsXML(stsPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(stsPath), err))
+		} else {
+			stats.ZMWs = zmws
+			stats.Sources = append(stats.Sources, stsPath)
+		}
+	}
+
+	datasets, _ := filepath.Glob(filepath.Join(hifiDir, movieName+".hifi_reads.*.consensusreadset.xml"))
+	for _, dsPath := range datasets {
+		ds, err := ParseDataSetFile(dsPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(dsPath), err))
+			continue
+		}
+		if ds.Barcode == "" {
+			continue
+		}
+		s := ReadStats{HiFiYield: ds.TotalLength, ReadCount: ds.NumRecords}
+		if ds.NumRecords > 0 {
+			s.MeanReadLength = ds.TotalLength / ds.NumRecords
+		}
+		stats.Barcodes[ds.Barcode] = stats.Barcodes[ds.Barcode].merge(s)
+		stats.Sources = append(stats.Sources, dsPath)
+	}
+
+	if len(stats.Sources) == 0 {
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return nil, nil
+	}
+	if len(errs) > 0 {
+		return stats, errs[0]
+	}
+	return stats, nil
+}
+
+// pbReport represents a pbreports JSON document as written for CCS reports.
+type pbReport struct {
+	Attributes []struct {
+		ID    string          `json:"id"`
+		Value json.RawMessage `json:"value"`
+	} `json:"attributes"`
+}
+
+// ccsReportFields maps the final component of pbreports attribute ids to ReadStats fields.
+var ccsReportFields = map[string]func(*ReadStats, float64){
+	"total_number_of_ccs_bases": func(s *ReadStats, v float64) { s.HiFiYield = int64(v) },
+	"number_of_ccs_reads":       func(s *ReadStats, v float64) { s.ReadCount = int64(v) },
+	"mean_ccs_readlength":       func(s *ReadStats, v float64) { s.MeanReadLength = int64(v) },
+	"median_ccs_readlength":     func(s *ReadStats, v float64) { s.MedianReadLength = int64(v) },
+	"ccs_readlength_n50":        func(s *ReadStats, v float64) { s.N50 = int64(v) },
+	"mean_ccs_readquality":      func(s *ReadStats, v float64) { s.MeanQuality = v },
+	"mean_qv":                   func(s *ReadStats, v float64) { s.MeanQuality = v },
+}
+
+// parseCCSReport reads cell-level HiFi statistics from a pbreports ccs_report.json.
+func parseCCSReport(path string) (ReadStats, error) {
+	var stats ReadStats
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stats, err
+	}
+	var report pbReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return stats, err
+	}
+	for _, attr := range report.Attributes {
+		id := attr.ID
+		if i := strings.LastIndex(id, "."); i >= 0 {
+			id = id[i+1:]
+		}
+		set, ok := ccsReportFields[id]
+		if !ok {
+			continue
+		}
+		var v float64
+		if err := json.Unmarshal(attr.Value, &v); err != nil {
+			continue // Non-numeric values (e.g. "NA") are not reported
+		}
+		set(&stats, v)
+	}
+	return stats, nil
+}
+
+// pipeStats represents the parts of an sts.xml file that are used.
+type pipeStats struct {
+	NumSequencingZmws int64 `xml:"NumSequencingZmws"`
+}
+
+// parseStsXML reads the number of sequencing ZMWs from an sts.xml file.
+func parseStsXML(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var ps pipeStats
+	if err := xml.NewDecoder(file).Decode(&ps); err != nil {
+		return 0, err
+	}
+	return ps.NumSequencingZmws, nil
+}
@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timestampLayouts lists the layouts seen in Revio metadata XML, most specific first.
+// Layouts without a zone are interpreted in the local time zone of this host.
+var timestampLayouts = []struct {
+	layout string
+	zoned  bool
+}{
+	{time.RFC3339Nano, true},
+	{"2006-01-02T15:04:05.999999999", false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02", false},
+}
+
+// ParseTimestamp parses an xs:dateTime value as written by the instrument.
+// Values with an explicit offset (or Z) keep it; zone-less values are taken as local time.
+// An empty string yields the zero time and no error.
+func ParseTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, l := range timestampLayouts {
+		var t time.Time
+		var err error
+		if l.zoned {
+			t, err = time.Parse(l.layout, value)
+		} else {
+			t, err = time.ParseInLocation(l.layout, value, time.Local)
+		}
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", value)
+}
+
+// runNamePattern matches Revio run directory names such as r84297_20250922_085610.
+var runNamePattern = regexp.MustCompile(`^r[0-9]+_([0-9]{8})(?:_([0-9]{6}))?`)
+
+// InferDateFromRunName derives the run start time from a Revio run name.
+// The instrument names runs after its local clock, so the result is in local time.
+func InferDateFromRunName(runName string) (time.Time, bool) {
+	m := runNamePattern.FindStringSubmatch(runName)
+	if m == nil {
+		return time.Time{}, false
+	}
+	if m[2] != "" {
+		if t, err := time.ParseInLocation("20060102150405", m[1]+m[2], time.Local); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.ParseInLocation("20060102", m[1], time.Local)
+	if err != nil || t.Year() < 2000 {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,615 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Item is one entry of the browser list, e.g. a run.
+type Item struct {
+	Name     string
+	Status   string // Shown after the name, e.g. complete or pending
+	Info     string // Dates and counts shown after the status
+	Keywords string // Additional text matched by the search, e.g. biosample names
+	Disabled bool   // The item cannot be chosen, e.g. a pending run
+}
+
+// Sample is a checkable entry of the detail pane, e.g. a biosample.
+type Sample struct {
+	Name string
+	Info string // Barcode, size and similar
+}
+
+// Detail is the content of the detail pane for an item.
+type Detail struct {
+	Lines   []string
+	Samples []Sample
+}
+
+// Choice is an item chosen in the browser.
+type Choice struct {
+	Index   int      // Index in Browser.Items
+	Samples []string // Checked samples; nil when all samples are checked
+}
+
+// Browser is a full-screen list of items with fuzzy search, a detail pane and
+// checkboxes for the samples of each item.
+type Browser struct {
+	Title string
+	Items []Item
+	// Load returns the detail pane of item i. It is called once per item, in the
+	// background by Run with a context that is cancelled when Run returns.
+	Load func(ctx context.Context, i int) Detail
+
+	query     string
+	searching bool
+	visible   []int // Indexes of the items matching query, best match first
+	cursor    int   // Position in visible
+	offset    int   // First visible row of the list
+	inSamples bool  // Focus is on the samples of the current item
+	sample    int   // Cursor in the samples of the current item
+	picked    map[int]bool
+	unchecked map[int]map[string]bool // Samples unchecked per item
+	details   map[int]*Detail
+	message   string
+
+	// While Run is active, details are loaded by a background goroutine so that
+	// slow file systems do not block the keyboard.
+	requests  chan int    // Item to load next; holds at most the latest request
+	results   chan loaded // Details loaded in the background
+	requested int         // Item of the pending request
+}
+
+// loaded is a detail pane loaded in the background.
+type loaded struct {
+	index  int
+	detail Detail
+}
+
+// loadingDetail is shown while the detail pane of an item is loaded.
+var loadingDetail = &Detail{Lines: []string{"Loading..."}}
+
+// action is the outcome of a key press.
+type action int
+
+const (
+	actionNone action = iota
+	actionDone
+	actionQuit
+)
+
+// Run shows the browser on t until the user confirms or quits. It returns the
+// chosen items, or nil when the user quits.
+func (b *Browser) Run(ctx context.Context, t *Terminal) ([]Choice, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	b.startLoader(ctx)
+	defer func() { b.requests, b.results = nil, nil }()
+
+	b.filter()
+	redraw := true
+	lastWidth, lastHeight := 0, 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if b.collect() {
+			redraw = true
+		}
+		width, height := t.Size()
+		if redraw || width != lastWidth || height != lastHeight {
+			t.Draw(b.Render(width, height))
+			lastWidth, lastHeight = width, height
+		}
+		key, ok, err := t.ReadKey()
+		if err != nil {
+			return nil, err
+		}
+		redraw = ok
+		if !ok {
+			continue
+		}
+		switch b.Update(key, height) {
+		case actionDone:
+			return b.Choices(), nil
+		case actionQuit:
+			return nil, nil
+		}
+	}
+}
+
+// filter recomputes the visible items for the current query.
+func (b *Browser) filter() {
+	type match struct{ index, score int }
+	var matches []match
+	for i, item := range b.Items {
+		score, ok := Match(b.query, item.Name)
+		if !ok {
+			if score, ok = Match(b.query, item.Keywords); !ok {
+				continue
+			}
+			score -= 10 // Prefer matches on the name
+		}
+		matches = append(matches, match{i, score})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	b.visible = b.visible[:0]
+	for _, m := range matches {
+		b.visible = append(b.visible, m.index)
+	}
+	b.cursor, b.offset, b.inSamples, b.sample = 0, 0, false, 0
+}
+
+// current returns the index of the item under the cursor, or -1 if none is visible.
+func (b *Browser) current() int {
+	if b.cursor < len(b.visible) {
+		return b.visible[b.cursor]
+	}
+	return -1
+}
+
+// startLoader starts the goroutine that loads detail panes for Run until ctx is done.
+func (b *Browser) startLoader(ctx context.Context) {
+	if b.Load == nil {
+		return
+	}
+	b.requests = make(chan int, 1)
+	b.results = make(chan loaded)
+	b.requested = -1
+	requests, results := b.requests, b.results
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case i := <-requests:
+				l := loaded{index: i, detail: b.Load(ctx, i)}
+				select {
+				case results <- l:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
+
+// collect stores the detail panes loaded in the background. It reports
+// whether one arrived, so that the screen is redrawn.
+func (b *Browser) collect() bool {
+	got := false
+	for {
+		select {
+		case l := <-b.results:
+			if b.details == nil {
+				b.details = make(map[int]*Detail)
+			}
+			b.details[l.index] = &l.detail
+			if b.requested == l.index {
+				b.requested = -1
+			}
+			got = true
+		default:
+			return got
+		}
+	}
+}
+
+// detail returns the detail pane of item i, loading it on first use. While
+// Run is active, it is loaded in the background and a placeholder is returned;
+// a pending request for another item is replaced, so that the item under the
+// cursor is loaded next.
+func (b *Browser) detail(i int) *Detail {
+	if b.details == nil {
+		b.details = make(map[int]*Detail)
+	}
+	if d, ok := b.details[i]; ok {
+		return d
+	}
+	if b.requests != nil {
+		if b.requested != i {
+			select {
+			case <-b.requests: // Drop the request for an item no longer shown
+			default:
+			}
+			b.requests <- i
+			b.requested = i
+		}
+		return loadingDetail
+	}
+	d := &Detail{}
+	if b.Load != nil {
+		*d = b.Load(context.Background(), i)
+	}
+	b.details[i] = d
+	return d
+}
+
+// checked reports whether sample name of item i is checked.
+func (b *Browser) checked(i int, name string) bool {
+	return !b.unchecked[i][name]
+}
+
+// setChecked checks or unchecks a sample of item i.
+func (b *Browser) setChecked(i int, name string, on bool) {
+	if b.unchecked == nil {
+		b.unchecked = make(map[int]map[string]bool)
+	}
+	if b.unchecked[i] == nil {
+		b.unchecked[i] = make(map[string]bool)
+	}
+	if on {
+		delete(b.unchecked[i], name)
+	} else {
+		b.unchecked[i][name] = true
+	}
+}
+
+// pick marks item i as chosen, or reports why it cannot be.
+func (b *Browser) pick(i int, on bool) {
+	if on && b.Items[i].Disabled {
+		b.message = fmt.Sprintf("%s is %s and cannot be selected", b.Items[i].Name, b.Items[i].Status)
+		return
+	}
+	if b.picked == nil {
+		b.picked = make(map[int]bool)
+	}
+	if on {
+		b.picked[i] = true
+	} else {
+		delete(b.picked, i)
+	}
+}
+
+// Update applies a key press; height is the terminal height, for paging.
+func (b *Browser) Update(key Key, height int) action {
+	b.message = ""
+	if b.visible == nil {
+		b.filter()
+	}
+	if key.Code == KeyCtrlC {
+		return actionQuit
+	}
+	if b.searching {
+		b.updateSearch(key)
+		return actionNone
+	}
+	cur := b.current()
+	page := max(listHeight(height)-1, 1)
+
+	switch {
+	case key.Code == KeyEnter:
+		if len(b.Choices()) > 0 {
+			return actionDone
+		}
+		if b.message == "" {
+			b.message = "Nothing selected: pick a complete run with space"
+		}
+	case key.Code == KeyEscape && b.inSamples:
+		b.inSamples = false
+	case key.Code == KeyEscape && b.query != "":
+		b.query = ""
+		b.filter()
+	case key.Code == KeyEscape, key.Code == KeyRune && key.Rune == 'q':
+		return actionQuit
+	case key.Code == KeyRune && key.Rune == '/':
+		b.searching, b.inSamples = true, false
+	case key.Code == KeyTab, key.Code == KeyBackTab, key.Code == KeyRight, key.Code == KeyLeft:
+		toSamples := key.Code == KeyRight || (key.Code != KeyLeft && !b.inSamples)
+		if toSamples && cur >= 0 && len(b.detail(cur).Samples) > 0 {
+			b.inSamples = true
+		} else {
+			b.inSamples = false
+		}
+	case b.inSamples:
+		b.updateSamples(key, cur, page)
+	default:
+		b.updateList(key, cur, page)
+	}
+	return actionNone
+}
+
+// updateSearch edits the search query.
+func (b *Browser) updateSearch(key Key) {
+	switch key.Code {
+	case KeyEnter, KeyDown, KeyTab:
+		b.searching = false
+	case KeyEscape:
+		b.searching, b.query = false, ""
+		b.filter()
+	case KeyBackspace:
+		if r := []rune(b.query); len(r) > 0 {
+			b.query = string(r[:len(r)-1])
+			b.filter()
+		}
+	case KeyCtrlU:
+		b.query = ""
+		b.filter()
+	case KeyRune:
+		b.query += string(key.Rune)
+		b.filter()
+	}
+}
+
+// updateList handles keys while the list has the focus.
+func (b *Browser) updateList(key Key, cur, page int) {
+	switch {
+	case key.Code == KeyUp, key.Code == KeyRune && key.Rune == 'k':
+		b.move(-1)
+	case key.Code == KeyDown, key.Code == KeyRune && key.Rune == 'j':
+		b.move(1)
+	case key.Code == KeyPageUp:
+		b.move(-page)
+	case key.Code == KeyPageDown:
+		b.move(page)
+	case key.Code == KeyHome:
+		b.move(-len(b.visible))
+	case key.Code == KeyEnd:
+		b.move(len(b.visible))
+	case key.Code == KeyRune && key.Rune == ' ' && cur >= 0:
+		b.pick(cur, !b.picked[cur])
+		if b.message == "" {
+			b.move(1)
+		}
+	case key.Code == KeyRune && key.Rune == 'a':
+		// Pick every selectable item shown, or none if all are picked already
+		all := true
+		for _, i := range b.visible {
+			all = all && (b.Items[i].Disabled || b.picked[i])
+		}
+		for _, i := range b.visible {
+			if !b.Items[i].Disabled {
+				b.pick(i, !all)
+			}
+		}
+	}
+}
+
+// updateSamples handles keys while the samples of item cur have the focus.
+func (b *Browser) updateSamples(key Key, cur, page int) {
+	samples := b.detail(cur).Samples
+	switch {
+	case key.Code == KeyUp, key.Code == KeyRune && key.Rune == 'k':
+		b.sample = max(b.sample-1, 0)
+	case key.Code == KeyDown, key.Code == KeyRune && key.Rune == 'j':
+		b.sample = min(b.sample+1, len(samples)-1)
+	case key.Code == KeyPageUp:
+		b.sample = max(b.sample-page, 0)
+	case key.Code == KeyPageDown:
+		b.sample = min(b.sample+page, len(samples)-1)
+	case key.Code == KeyHome:
+		b.sample = 0
+	case key.Code == KeyEnd:
+		b.sample = len(samples) - 1
+	case key.Code == KeyRune && key.Rune == ' ':
+		name := samples[b.sample].Name
+		b.setChecked(cur, name, !b.checked(cur, name))
+		b.pick(cur, true) // Choosing samples chooses the item
+		b.sample = min(b.sample+1, len(samples)-1)
+	case key.Code == KeyRune && key.Rune == 'a':
+		all := len(b.unchecked[cur]) == 0
+		for _, s := range samples {
+			b.setChecked(cur, s.Name, !all)
+		}
+		b.pick(cur, true)
+	}
+}
+
+// move moves the list cursor by delta rows.
+func (b *Browser) move(delta int) {
+	if len(b.visible) == 0 {
+		return
+	}
+	b.cursor = min(max(b.cursor+delta, 0), len(b.visible)-1)
+	b.sample = 0
+}
+
+// Choices returns the picked items in list order, or the item under the cursor
+// if none is picked. Items without a checked sample are left out with a message.
+func (b *Browser) Choices() []Choice {
+	var indexes []int
+	for i := range b.Items {
+		if b.picked[i] {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		cur := b.current()
+		if cur < 0 {
+			return nil
+		}
+		if b.Items[cur].Disabled {
+			b.message = fmt.Sprintf("%s is %s and cannot be selected", b.Items[cur].Name, b.Items[cur].Status)
+			return nil
+		}
+		indexes = append(indexes, cur)
+	}
+
+	var choices []Choice
+	for _, i := range indexes {
+		c := Choice{Index: i}
+		if len(b.unchecked[i]) > 0 {
+			c.Samples = []string{}
+			for _, s := range b.detail(i).Samples {
+				if b.checked(i, s.Name) {
+					c.Samples = append(c.Samples, s.Name)
+				}
+			}
+			if len(c.Samples) == 0 {
+				b.message = fmt.Sprintf("No biosamples checked in %s", b.Items[i].Name)
+				return nil
+			}
+		}
+		choices = append(choices, c)
+	}
+	return choices
+}
+
+// listHeight returns the number of list rows on a terminal of the given height:
+// all lines but the title, search, status and help lines.
+func listHeight(height int) int {
+	return max(height-4, 1)
+}
+
+// Render draws the browser for a terminal of the given size.
+func (b *Browser) Render(width, height int) []string {
+	if b.visible == nil {
+		b.filter()
+	}
+	rows := listHeight(height)
+	listWidth := max(width*45/100, min(width, 30))
+	detailWidth := max(width-listWidth-3, 0)
+
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	} else if b.cursor >= b.offset+rows {
+		b.offset = b.cursor - rows + 1
+	}
+
+	lines := make([]string, 0, height)
+	title := fmt.Sprintf(" %s (%d of %d, %d selected)", b.Title, len(b.visible), len(b.Items), len(b.picked))
+	lines = append(lines, style(fit(title, width), sgrReverse+";"+sgrBold))
+	search := "Search: " + b.query
+	if b.searching {
+		lines = append(lines, style(fit(search+"_", width), sgrBold))
+	} else if b.query != "" {
+		lines = append(lines, fit(search, width))
+	} else {
+		lines = append(lines, style(fit("Press / to search", width), sgrDim))
+	}
+
+	list := b.renderList(listWidth, rows)
+	var pane []string
+	if cur := b.current(); cur >= 0 && detailWidth > 0 {
+		pane = b.renderDetail(cur, detailWidth, rows)
+	}
+	for r := 0; r < rows; r++ {
+		line := list[r]
+		if detailWidth > 0 {
+			right := ""
+			if r < len(pane) {
+				right = pane[r]
+			}
+			line += " " + style("│", sgrDim) + " " + right
+		}
+		lines = append(lines, line)
+	}
+
+	if b.message != "" {
+		lines = append(lines, style(fit(b.message, width), sgrYellow))
+	} else {
+		lines = append(lines, "")
+	}
+	help := "↑↓ move  space select  a all  tab biosamples  / search  enter copy  q quit"
+	if b.inSamples {
+		help = "↑↓ move  space check  a all/none  tab runs  enter copy  q quit"
+	} else if b.searching {
+		help = "type to filter  enter done  esc clear"
+	}
+	lines = append(lines, style(fit(help, width), sgrDim))
+	return lines
+}
+
+// renderList draws the visible items, padded to width, one per row.
+func (b *Browser) renderList(width, rows int) []string {
+	lines := make([]string, rows)
+	for r := range lines {
+		pos := b.offset + r
+		if pos >= len(b.visible) {
+			lines[r] = fit("", width)
+			continue
+		}
+		i := b.visible[pos]
+		item := b.Items[i]
+		mark := "[ ]"
+		switch {
+		case item.Disabled:
+			mark = "   "
+		case b.picked[i]:
+			mark = "[x]"
+		}
+		text := fit(fmt.Sprintf("%s %s  %s  %s", mark, item.Name, item.Status, item.Info), width)
+		codes := sgrGreen
+		if item.Disabled {
+			codes = sgrYellow
+		}
+		if pos == b.cursor {
+			codes += ";" + sgrReverse
+			if b.inSamples {
+				codes += ";" + sgrDim
+			}
+		}
+		lines[r] = style(text, codes)
+	}
+	return lines
+}
+
+// renderDetail draws the detail pane of item i, scrolled so that the sample
+// cursor is visible.
+func (b *Browser) renderDetail(i, width, rows int) []string {
+	d := b.detail(i)
+	var lines []string
+	for _, line := range d.Lines {
+		lines = append(lines, fit(line, width))
+	}
+	if len(d.Samples) == 0 {
+		return lines
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	checked := 0
+	for _, s := range d.Samples {
+		if b.checked(i, s.Name) {
+			checked++
+		}
+	}
+	lines = append(lines, style(fit(fmt.Sprintf("Biosamples (%d of %d checked):", checked, len(d.Samples)), width), sgrBold))
+	first := len(lines)
+	for n, s := range d.Samples {
+		mark := "[x]"
+		if !b.checked(i, s.Name) {
+			mark = "[ ]"
+		}
+		text := fit(fmt.Sprintf("%s %s  %s", mark, s.Name, s.Info), width)
+		if b.inSamples && n == b.sample {
+			text = style(text, sgrReverse)
+		}
+		lines = append(lines, text)
+	}
+
+	// Scroll so that the sample cursor stays on screen
+	if offset := first + b.sample - rows + 1; b.inSamples && offset > 0 {
+		lines = lines[offset:]
+	}
+	return lines
+}
+
+// SGR parameters used by the browser.
+const (
+	sgrBold    = "1"
+	sgrDim     = "2"
+	sgrReverse = "7"
+	sgrGreen   = "32"
+	sgrYellow  = "33"
+)
+
+// style wraps s in the SGR sequence for codes.
+func style(s, codes string) string {
+	return "\x1b[" + codes + "m" + s + "\x1b[0m"
+}
+
+// fit truncates or pads s to exactly width columns, assuming one column per rune.
+func fit(s string, width int) string {
+	r := []rune(s)
+	switch {
+	case width <= 0:
+		return ""
+	case len(r) > width:
+		return string(r[:width-1]) + "…"
+	default:
+		return s + strings.Repeat(" ", width-len(r))
+	}
+}
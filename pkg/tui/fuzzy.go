@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match reports whether the characters of pattern appear in text in order,
+// ignoring case, and scores the match: consecutive characters and characters
+// at the start of a word score higher, gaps lower. An empty pattern matches
+// everything with score 0.
+func Match(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, pi, last := 0, 0, -1
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if t[ti] != p[pi] {
+			continue
+		}
+		switch {
+		case last == ti-1:
+			score += 5 // Consecutive
+		case last >= 0:
+			score -= min(ti-last-1, 5) // Gap
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 3 // Start of a word
+		}
+		score++
+		last = ti
+		pi++
+	}
+	return score, pi == len(p)
+}
@@ -0,0 +1,91 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyCode identifies a key; printable characters are KeyRune.
+type KeyCode int
+
+// Keys understood by the browser.
+const (
+	KeyUnknown KeyCode = iota
+	KeyRune
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyTab
+	KeyBackTab
+	KeyBackspace
+	KeyEscape
+	KeyCtrlC
+	KeyCtrlU
+)
+
+// Key is a decoded key press.
+type Key struct {
+	Code KeyCode
+	Rune rune // Set for KeyRune
+}
+
+// csiKeys maps the final byte of CSI and SS3 sequences to keys.
+var csiKeys = map[byte]KeyCode{
+	'A': KeyUp, 'B': KeyDown, 'C': KeyRight, 'D': KeyLeft,
+	'H': KeyHome, 'F': KeyEnd, 'Z': KeyBackTab,
+}
+
+// tildeKeys maps the parameter of "ESC [ n ~" sequences to keys.
+var tildeKeys = map[string]KeyCode{
+	"1": KeyHome, "7": KeyHome, "4": KeyEnd, "8": KeyEnd,
+	"5": KeyPageUp, "6": KeyPageDown,
+}
+
+// decodeKey decodes the first key in b and returns it with the number of bytes
+// it used. b must not be empty.
+func decodeKey(b []byte) (Key, int) {
+	switch c := b[0]; {
+	case c == 0x1b:
+		return decodeEscape(b)
+	case c == '\r' || c == '\n':
+		return Key{Code: KeyEnter}, 1
+	case c == '\t':
+		return Key{Code: KeyTab}, 1
+	case c == 0x7f || c == 0x08:
+		return Key{Code: KeyBackspace}, 1
+	case c == 0x03:
+		return Key{Code: KeyCtrlC}, 1
+	case c == 0x15:
+		return Key{Code: KeyCtrlU}, 1
+	case c < 0x20:
+		return Key{Code: KeyUnknown}, 1
+	}
+	r, n := utf8.DecodeRune(b)
+	if r == utf8.RuneError {
+		return Key{Code: KeyUnknown}, n
+	}
+	return Key{Code: KeyRune, Rune: r}, n
+}
+
+// decodeEscape decodes a key starting with ESC: a lone Escape or a CSI or SS3 sequence.
+func decodeEscape(b []byte) (Key, int) {
+	if len(b) < 3 || (b[1] != '[' && b[1] != 'O') {
+		return Key{Code: KeyEscape}, 1
+	}
+	// Parameters and intermediates run up to the final byte 0x40-0x7e
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		return Key{Code: KeyUnknown}, len(b)
+	}
+	params := string(b[2:end])
+	if b[end] == '~' {
+		return Key{Code: tildeKeys[params]}, end + 1
+	}
+	return Key{Code: csiKeys[b[end]]}, end + 1
+}
@@ -0,0 +1,184 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+	"github.com/schnurbe/revio-copy/pkg/ui"
+)
+
+// Progress draws a live progress bar on the last line of a terminal while the
+// copy output scrolls above it. It is the io.Writer for the copy output and a
+// copyfiles.ProgressRecorder that forwards every file event to the next
+// recorder; backends that report bytes move the bar while a file is copied.
+type Progress struct {
+	next  copyfiles.Recorder
+	out   io.Writer
+	width func() int
+
+	mu         sync.Mutex
+	files      int // Files done, including files already present
+	totalFiles int
+	failed     int
+	bytes      int64 // Bytes of the files done
+	totalBytes int64
+	active     []string         // Base names of the files being copied
+	inFlight   map[string]int64 // Bytes copied so far per destination being copied
+	start      time.Time
+	drawn      time.Time // Last time the bar was drawn for byte progress
+	line       []byte    // Output not yet terminated by a newline
+}
+
+// progressInterval limits how often byte progress redraws the bar.
+const progressInterval = 100 * time.Millisecond
+
+// NewProgress returns a progress bar for copying mappings that writes to out
+// and forwards copy events to next, which may be nil.
+func NewProgress(out *os.File, mappings []*fileops.FileMapping, next copyfiles.Recorder) *Progress {
+	p := &Progress{next: next, out: out, start: time.Now()}
+	p.width = func() int {
+		if w, _, err := termSize(int(out.Fd())); err == nil && w > 0 {
+			return w
+		}
+		return 80
+	}
+	for _, m := range mappings {
+		for _, f := range m.Files() {
+			p.totalFiles++
+			if info, err := os.Stat(f.Source); err == nil {
+				p.totalBytes += info.Size()
+			}
+		}
+	}
+	return p
+}
+
+// Write prints the complete lines of b above the progress bar.
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.line = append(p.line, b...)
+	i := strings.LastIndexByte(string(p.line), '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	var out strings.Builder
+	out.WriteString("\r" + clearLine)
+	out.Write(p.line[:i+1])
+	out.WriteString(p.bar())
+	p.line = append(p.line[:0], p.line[i+1:]...)
+	_, err := io.WriteString(p.out, out.String())
+	return len(b), err
+}
+
+// FileStarted implements copyfiles.Recorder.
+func (p *Progress) FileStarted(m *fileops.FileMapping, src, dest string) {
+	p.update(func() { p.active = append(p.active, filepath.Base(dest)) })
+	if p.next != nil {
+		p.next.FileStarted(m, src, dest)
+	}
+}
+
+// FileProgress implements copyfiles.ProgressRecorder.
+func (p *Progress) FileProgress(m *fileops.FileMapping, src, dest string, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inFlight == nil {
+		p.inFlight = make(map[string]int64)
+	}
+	p.inFlight[dest] = bytes
+	if now := time.Now(); now.Sub(p.drawn) >= progressInterval {
+		p.drawn = now
+		io.WriteString(p.out, "\r"+clearLine+p.bar())
+	}
+}
+
+// FileDone implements copyfiles.Recorder.
+func (p *Progress) FileDone(m *fileops.FileMapping, result *copyfiles.FileResult, skipped bool) {
+	p.update(func() {
+		p.files++
+		p.bytes += result.Bytes
+		p.finished(result.Dest)
+	})
+	if p.next != nil {
+		p.next.FileDone(m, result, skipped)
+	}
+}
+
+// FileFailed implements copyfiles.Recorder.
+func (p *Progress) FileFailed(m *fileops.FileMapping, src, dest string, err error) {
+	p.update(func() {
+		p.files++
+		p.failed++
+		p.finished(dest)
+	})
+	if p.next != nil {
+		p.next.FileFailed(m, src, dest, err)
+	}
+}
+
+// Finish removes the progress bar and prints any unterminated output.
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.out, "\r"+clearLine+string(p.line))
+	p.line = nil
+}
+
+// update applies f and redraws the progress bar.
+func (p *Progress) update(f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f()
+	io.WriteString(p.out, "\r"+clearLine+p.bar())
+}
+
+// finished removes dest from the files being copied.
+func (p *Progress) finished(dest string) {
+	delete(p.inFlight, dest)
+	name := filepath.Base(dest)
+	for i, a := range p.active {
+		if a == name {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// bar renders the progress line: a bar, files, bytes, rate and current file.
+func (p *Progress) bar() string {
+	bytes := p.bytes
+	for _, n := range p.inFlight {
+		bytes += n
+	}
+	fraction := 0.0
+	switch {
+	case p.totalBytes > 0:
+		fraction = float64(bytes) / float64(p.totalBytes)
+	case p.totalFiles > 0:
+		fraction = float64(p.files) / float64(p.totalFiles)
+	}
+	fraction = min(fraction, 1)
+	const barWidth = 20
+	filled := int(fraction * barWidth)
+	text := fmt.Sprintf("[%s%s] %3.0f%%  %d/%d files  %s / %s", strings.Repeat("#", filled),
+		strings.Repeat(".", barWidth-filled), fraction*100, p.files, p.totalFiles,
+		ui.FormatBytes(bytes), ui.FormatBytes(p.totalBytes))
+	if elapsed := time.Since(p.start).Seconds(); elapsed >= 1 && bytes > 0 {
+		text += fmt.Sprintf("  %s/s", ui.FormatBytes(int64(float64(bytes)/elapsed)))
+	}
+	if p.failed > 0 {
+		text += fmt.Sprintf("  %d failed", p.failed)
+	}
+	if len(p.active) > 0 {
+		text += "  " + strings.Join(p.active, ", ")
+	}
+	return style(strings.TrimRight(fit(text, p.width()-1), " "), sgrBold)
+}
@@ -0,0 +1,105 @@
+// Package tui implements the full-screen run browser and the live copy
+// progress bar used by process on interactive terminals.
+package tui
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ErrUnsupported is returned by Open on platforms without raw terminal support.
+var ErrUnsupported = errors.New("terminal UI is not supported on this platform")
+
+// ANSI escape sequences used to draw the screen.
+const (
+	altScreenOn  = "\x1b[?1049h"
+	altScreenOff = "\x1b[?1049l"
+	cursorHide   = "\x1b[?25l"
+	cursorShow   = "\x1b[?25h"
+	cursorHome   = "\x1b[H"
+	clearLine    = "\x1b[K"
+	clearBelow   = "\x1b[J"
+)
+
+// IsTerminal reports whether f is an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// Terminal is a terminal in raw mode showing the alternate screen.
+type Terminal struct {
+	in, out *os.File
+	restore func() error
+	buf     []byte // Input read but not yet decoded
+}
+
+// Open switches the terminal of in and out to raw mode and the alternate
+// screen. Close must be called to restore it.
+func Open(in, out *os.File) (*Terminal, error) {
+	if !IsTerminal(in) || !IsTerminal(out) {
+		return nil, errors.New("not a terminal")
+	}
+	if _, _, err := termSize(int(out.Fd())); err != nil {
+		return nil, err
+	}
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(out, altScreenOn+cursorHide)
+	return &Terminal{in: in, out: out, restore: restore}, nil
+}
+
+// Close leaves the alternate screen and restores the previous terminal mode.
+func (t *Terminal) Close() error {
+	io.WriteString(t.out, cursorShow+altScreenOff)
+	return t.restore()
+}
+
+// Size returns the width and height of the terminal, or 80x24 if unknown.
+func (t *Terminal) Size() (width, height int) {
+	width, height, err := termSize(int(t.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// ReadKey returns the next key pressed. It returns false when no key was
+// pressed within about 100ms, so that callers can check for cancellation.
+func (t *Terminal) ReadKey() (Key, bool, error) {
+	if len(t.buf) == 0 {
+		chunk := make([]byte, 64)
+		n, err := t.in.Read(chunk)
+		t.buf = append(t.buf, chunk[:n]...)
+		if n == 0 {
+			// Raw reads time out with no input, which os.File reports as io.EOF
+			if err != nil && err != io.EOF {
+				return Key{}, false, err
+			}
+			return Key{}, false, nil
+		}
+	}
+	key, n := decodeKey(t.buf)
+	t.buf = t.buf[n:]
+	return key, true, nil
+}
+
+// Draw replaces the screen contents with lines.
+func (t *Terminal) Draw(lines []string) {
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString(clearLine)
+	}
+	b.WriteString(clearBelow)
+	io.WriteString(t.out, b.String())
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd)
+
+package tui
+
+// makeRaw is not implemented on this platform; the terminal UI is unavailable.
+func makeRaw(fd int) (func() error, error) {
+	return nil, ErrUnsupported
+}
+
+// termSize is not implemented on this platform.
+func termSize(fd int) (int, int, error) {
+	return 0, 0, ErrUnsupported
+}
@@ -0,0 +1,199 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schnurbe/revio-copy/pkg/copyfiles"
+	"github.com/schnurbe/revio-copy/pkg/fileops"
+)
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Key
+		n    int
+	}{
+		{"\x1b[A", Key{Code: KeyUp}, 3},
+		{"\x1bOB", Key{Code: KeyDown}, 3},
+		{"\x1b[6~x", Key{Code: KeyPageDown}, 4},
+		{"\x1b[1;5C", Key{Code: KeyRight}, 6},
+		{"\x1b", Key{Code: KeyEscape}, 1},
+		{"\r", Key{Code: KeyEnter}, 1},
+		{"\x7f", Key{Code: KeyBackspace}, 1},
+		{"\x03", Key{Code: KeyCtrlC}, 1},
+		{"é!", Key{Code: KeyRune, Rune: 'é'}, 2},
+	}
+	for _, tt := range tests {
+		got, n := decodeKey([]byte(tt.in))
+		if got != tt.want || n != tt.n {
+			t.Fatalf("decodeKey(%q) = %+v, %d; want %+v, %d", tt.in, got, n, tt.want, tt.n)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	if _, ok := Match("r84b", "r84001_20250922_100000_B"); !ok {
+		t.Fatal("expected subsequence match")
+	}
+	if _, ok := Match("xyz", "r84001"); ok {
+		t.Fatal("unexpected match")
+	}
+	exact, _ := Match("lab1", "LAB1_sample")
+	scattered, _ := Match("lab1", "l_a_b_1")
+	if exact <= scattered {
+		t.Fatalf("consecutive match should score higher: %d <= %d", exact, scattered)
+	}
+}
+
+func runes(s string) []Key {
+	var keys []Key
+	for _, r := range s {
+		keys = append(keys, Key{Code: KeyRune, Rune: r})
+	}
+	return keys
+}
+
+func TestBrowser(t *testing.T) {
+	b := &Browser{
+		Title: "Runs",
+		Items: []Item{
+			{Name: "RUN_A", Status: "complete", Keywords: "LAB1_S1 LAB1_S2"},
+			{Name: "RUN_B", Status: "pending", Disabled: true},
+			{Name: "RUN_C", Status: "complete", Keywords: "LAB2_S1"},
+		},
+		Load: func(ctx context.Context, i int) Detail {
+			if i == 0 {
+				return Detail{Lines: []string{"Cells: 1"}, Samples: []Sample{{Name: "LAB1_S1"}, {Name: "LAB1_S2"}}}
+			}
+			return Detail{}
+		},
+	}
+
+	// Pending items cannot be chosen
+	b.Update(Key{Code: KeyDown}, 24)
+	if b.Update(Key{Code: KeyEnter}, 24) != actionNone || !strings.Contains(b.message, "pending") {
+		t.Fatalf("pending run must not be chosen, message %q", b.message)
+	}
+
+	// Search by biosample, then uncheck one sample of the match
+	keys := append([]Key{{Code: KeyRune, Rune: '/'}}, runes("lab1")...)
+	keys = append(keys, Key{Code: KeyEnter}, Key{Code: KeyTab}, Key{Code: KeyRune, Rune: ' '})
+	for _, k := range keys {
+		b.Update(k, 24)
+	}
+	if len(b.visible) == 0 || b.current() != 0 {
+		t.Fatalf("search: visible = %v", b.visible)
+	}
+	if got := b.Update(Key{Code: KeyEnter}, 24); got != actionDone {
+		t.Fatalf("enter: got action %v, message %q", got, b.message)
+	}
+	choices := b.Choices()
+	if len(choices) != 1 || choices[0].Index != 0 || strings.Join(choices[0].Samples, ",") != "LAB1_S2" {
+		t.Fatalf("unexpected choices: %+v", choices)
+	}
+
+	// 'a' picks every complete run shown; all samples of RUN_C are kept
+	b.Update(Key{Code: KeyEscape}, 24)
+	b.Update(Key{Code: KeyEscape}, 24)
+	b.Update(Key{Code: KeyRune, Rune: 'a'}, 24)
+	choices = b.Choices()
+	if len(choices) != 2 || choices[1].Index != 2 || choices[1].Samples != nil {
+		t.Fatalf("unexpected choices: %+v", choices)
+	}
+
+	// Every line fits the terminal
+	ansi := regexp.MustCompile("\x1b\\[[0-9;]*m")
+	lines := b.Render(60, 10)
+	if len(lines) != 10 {
+		t.Fatalf("got %d lines, want 10", len(lines))
+	}
+	for _, line := range lines {
+		if n := len([]rune(ansi.ReplaceAllString(line, ""))); n > 60 {
+			t.Fatalf("line too wide (%d): %q", n, line)
+		}
+	}
+	if b.Update(Key{Code: KeyRune, Rune: 'q'}, 24) != actionQuit {
+		t.Fatal("q must quit")
+	}
+}
+
+func TestBrowserLoadsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var loadCtx context.Context
+	b := &Browser{
+		Items: []Item{{Name: "RUN_A"}},
+		Load: func(ctx context.Context, i int) Detail {
+			<-release
+			loadCtx = ctx
+			return Detail{Lines: []string{"Cells: 1"}}
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.startLoader(ctx)
+
+	// A slow load must not block the screen
+	if d := b.detail(0); d != loadingDetail {
+		t.Fatalf("expected placeholder, got %+v", d)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.collect() {
+		if time.Now().After(deadline) {
+			t.Fatal("detail was not loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d := b.detail(0); len(d.Lines) != 1 || d.Lines[0] != "Cells: 1" {
+		t.Fatalf("unexpected detail %+v", d)
+	}
+
+	// Loads still running when the browser closes are cancelled
+	cancel()
+	if loadCtx.Err() == nil {
+		t.Fatal("expected the load context to be cancelled")
+	}
+}
+
+type events struct{ started, done int }
+
+func (e *events) FileStarted(m *fileops.FileMapping, src, dest string) { e.started++ }
+func (e *events) FileDone(m *fileops.FileMapping, result *copyfiles.FileResult, skipped bool) {
+	e.done++
+}
+func (e *events) FileFailed(m *fileops.FileMapping, src, dest string, err error) {}
+
+func TestProgress(t *testing.T) {
+	bam := "/runs/s.bam"
+	m := &fileops.FileMapping{SourceBAM: bam, SourcePBI: bam + ".pbi", DestBAM: "/out/S.bam", DestPBI: "/out/S.bam.pbi"}
+
+	var out bytes.Buffer
+	next := &events{}
+	p := &Progress{next: next, out: &out, width: func() int { return 80 }, totalFiles: 2, totalBytes: 200}
+	p.FileStarted(m, bam, m.DestBAM)
+	p.FileProgress(m, bam, m.DestBAM, 50)
+	if !strings.Contains(out.String(), " 25%  0/2 files") {
+		t.Fatalf("byte progress not shown: %q", out.String())
+	}
+	p.Write([]byte("Copying s.bam"))
+	if strings.Contains(out.String(), "Copying") {
+		t.Fatal("incomplete lines must be held back")
+	}
+	p.Write([]byte("\n"))
+	p.FileDone(m, &copyfiles.FileResult{Source: bam, Dest: m.DestBAM, Bytes: 100}, false)
+	if next.started != 1 || next.done != 1 {
+		t.Fatalf("events not forwarded: %+v", next)
+	}
+	got := out.String()
+	if !strings.Contains(got, "Copying s.bam\n") || !strings.Contains(got, " 50%  1/2 files") {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	if len(p.active) != 0 {
+		t.Fatalf("finished file still active: %v", p.active)
+	}
+}
@@ -1,6 +1,10 @@
 package ui
 
-import "github.com/fatih/color"
+import (
+	"os"
+
+	"github.com/fatih/color"
+)
 
 // Green prints a message in green.
 func Green(format string, a ...interface{}) {
@@ -12,6 +16,12 @@ func Yellow(format string, a ...interface{}) {
 	color.New(color.FgYellow).PrintfFunc()(format, a...)
 }
 
+// Warn prints a message in yellow to stderr, so that it stays out of
+// machine-readable output on stdout.
+func Warn(format string, a ...interface{}) {
+	color.New(color.FgYellow).Fprintf(os.Stderr, format, a...)
+}
+
 // Red prints a message in red.
 func Red(format string, a ...interface{}) {
 	color.New(color.FgRed).PrintfFunc()(format, a...)
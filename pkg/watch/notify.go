@@ -0,0 +1,150 @@
+// Package watch tracks runs below a runs directory over time: it notices changes
+// (filesystem events with a polling fallback) and remembers, across restarts,
+// which runs were seen pending, completed and delivered.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/schnurbe/revio-copy/pkg/logging"
+)
+
+// watchDepth is how deep below the root directories are watched:
+// <run>/<cell>/metadata, where the transfer markers and metadata XML appear.
+const watchDepth = 3
+
+// Notifier signals that the tree below Root may have changed. Filesystem events
+// are not delivered for changes made by other hosts on network filesystems such
+// as NFS, so the tree is also rescanned every Interval.
+type Notifier struct {
+	Root     string
+	Interval time.Duration // Rescan at least this often; default 1 minute
+	Poll     bool          // Only poll; do not use filesystem events
+	Debounce time.Duration // Quiet period after an event before notifying; default 2 seconds
+}
+
+// Start watches Root until ctx is cancelled. The returned channel receives a
+// value immediately, after each burst of events and every Interval; it is
+// closed when ctx is done. events reports whether filesystem events are used.
+func (n *Notifier) Start(ctx context.Context) (changes <-chan struct{}, events bool) {
+	interval := n.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	debounce := n.Debounce
+	if debounce <= 0 {
+		debounce = 2 * time.Second
+	}
+
+	out := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case out <- struct{}{}:
+		default: // A rescan is already pending
+		}
+	}
+
+	var watcher *fsnotify.Watcher
+	if !n.Poll {
+		w, err := fsnotify.NewWatcher()
+		if err == nil {
+			err = n.addTree(w, n.Root, 0)
+			if err != nil {
+				w.Close()
+			}
+		}
+		if err != nil {
+			logging.Debugf("watch: filesystem events unavailable, polling only: %v", err)
+		} else {
+			watcher = w
+		}
+	}
+
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		settle := time.NewTimer(debounce)
+		settle.Stop()
+		defer settle.Stop()
+
+		var eventsCh <-chan fsnotify.Event
+		var errorsCh <-chan error
+		if watcher != nil {
+			defer watcher.Close()
+			eventsCh, errorsCh = watcher.Events, watcher.Errors
+		}
+
+		notify()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				notify()
+			case <-settle.C:
+				notify()
+			case e, ok := <-eventsCh:
+				if !ok {
+					eventsCh = nil
+					continue
+				}
+				if e.Has(fsnotify.Create) {
+					if depth := n.depth(e.Name); depth > 0 && depth <= watchDepth {
+						if err := n.addTree(watcher, e.Name, depth); err != nil {
+							logging.Debugf("watch: %v", err)
+						}
+					}
+				}
+				settle.Reset(debounce)
+			case err, ok := <-errorsCh:
+				if !ok {
+					errorsCh = nil
+					continue
+				}
+				logging.Debugf("watch: filesystem event error: %v", err)
+			}
+		}
+	}()
+	return out, watcher != nil
+}
+
+// depth returns how many levels path is below Root.
+func (n *Notifier) depth(path string) int {
+	rel, err := filepath.Rel(n.Root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// addTree watches dir, which is depth levels below Root, and its subdirectories down to watchDepth.
+func (n *Notifier) addTree(w *fsnotify.Watcher, dir string, depth int) error {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil // Files and vanished entries need no watch
+	}
+	if err := w.Add(dir); err != nil {
+		return err
+	}
+	if depth >= watchDepth {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			if err := n.addTree(w, filepath.Join(dir, e.Name()), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
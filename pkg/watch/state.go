@@ -0,0 +1,115 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunState is what the watcher remembers about a run.
+type RunState struct {
+	Complete      bool      `json:"complete"`               // Last observed: every cell has its metadata XML
+	SeenPending   bool      `json:"seen_pending,omitempty"` // The run was observed while cells were pending
+	Baseline      bool      `json:"baseline,omitempty"`     // Already complete when the state was created
+	FirstSeen     time.Time `json:"first_seen"`
+	CompleteSince time.Time `json:"complete_since"` // Start of the current complete period
+	Delivered     time.Time `json:"delivered"`      // Zero until the run was delivered
+	OutputDir     string    `json:"output_dir,omitempty"`
+	Attempts      int       `json:"attempts,omitempty"` // Failed delivery attempts
+	LastAttempt   time.Time `json:"last_attempt"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Policy decides when a complete run is delivered.
+type Policy struct {
+	Settle      time.Duration // How long a run must stay complete before it is delivered
+	MaxAttempts int           // Failed attempts after which a run is given up; < 1 means 1
+	RetryDelay  time.Duration // Wait between failed attempts
+	Existing    bool          // Also deliver runs that were complete when the state was created
+}
+
+// Ready reports whether the run should be delivered now.
+func (rs *RunState) Ready(now time.Time, p Policy) bool {
+	switch {
+	case !rs.Complete || !rs.Delivered.IsZero():
+		return false
+	case rs.Baseline && !p.Existing:
+		return false
+	case now.Sub(rs.CompleteSince) < p.Settle:
+		return false
+	case rs.Attempts >= max(p.MaxAttempts, 1):
+		return false
+	case rs.Attempts > 0 && now.Sub(rs.LastAttempt) < p.RetryDelay:
+		return false
+	}
+	return true
+}
+
+// State is the watcher's persistent memory of runs, keyed by run name.
+type State struct {
+	path        string
+	Initialized bool                 `json:"initialized"` // The first scan has completed
+	Runs        map[string]*RunState `json:"runs"`
+}
+
+// StatePath returns the location of the watch state in a state directory.
+func StatePath(stateDir string) string {
+	return filepath.Join(stateDir, "watch.json")
+}
+
+// LoadState reads the state at path; a missing file yields an empty state.
+func LoadState(path string) (*State, error) {
+	s := &State{path: path, Runs: make(map[string]*RunState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("reading watch state %s: %w", path, err)
+	}
+	if s.Runs == nil {
+		s.Runs = make(map[string]*RunState)
+	}
+	return s, nil
+}
+
+// Observe records the current status of a run and returns its state. Runs seen
+// for the first time during the first scan of a new state are baseline runs.
+func (s *State) Observe(name string, complete bool, now time.Time) *RunState {
+	rs, ok := s.Runs[name]
+	if !ok {
+		rs = &RunState{FirstSeen: now, Baseline: complete && !s.Initialized}
+		s.Runs[name] = rs
+	}
+	switch {
+	case complete && !rs.Complete:
+		rs.CompleteSince = now
+	case !complete:
+		rs.SeenPending = true
+		rs.CompleteSince = time.Time{}
+	}
+	rs.Complete = complete
+	return rs
+}
+
+// Save writes the state atomically.
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
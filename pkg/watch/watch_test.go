@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateReady(t *testing.T) {
+	path := StatePath(t.TempDir())
+	s, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	policy := Policy{Settle: time.Minute, MaxAttempts: 2, RetryDelay: time.Hour}
+
+	// First scan: complete runs are baseline, pending runs are tracked
+	if rs := s.Observe("old", true, now); !rs.Baseline || rs.Ready(now.Add(time.Hour), policy) {
+		t.Fatalf("baseline run must not be ready: %+v", rs)
+	}
+	if rs := s.Observe("new", false, now); rs.Ready(now, policy) {
+		t.Fatalf("pending run must not be ready: %+v", rs)
+	}
+	s.Initialized = true
+	if err := s.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// After a restart the run completes and settles
+	s, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rs := s.Observe("new", true, now)
+	if !rs.SeenPending || rs.Ready(now.Add(30*time.Second), policy) {
+		t.Fatalf("run must settle first: %+v", rs)
+	}
+	if !rs.Ready(now.Add(time.Minute), policy) {
+		t.Fatalf("settled run must be ready: %+v", rs)
+	}
+	if !s.Observe("appeared", true, now).Ready(now.Add(time.Minute), policy) {
+		t.Fatal("run first seen complete after the first scan must be delivered")
+	}
+
+	// Failed attempts are retried after RetryDelay, up to MaxAttempts
+	rs.Attempts, rs.LastAttempt = 1, now
+	if rs.Ready(now.Add(time.Minute), policy) || !rs.Ready(now.Add(2*time.Hour), policy) {
+		t.Fatalf("unexpected retry behaviour: %+v", rs)
+	}
+	rs.Attempts = 2
+	if rs.Ready(now.Add(2*time.Hour), policy) {
+		t.Fatal("run must be given up after MaxAttempts")
+	}
+
+	rs.Attempts, rs.Delivered = 0, now
+	if rs.Ready(now.Add(2*time.Hour), policy) {
+		t.Fatal("delivered run must never be ready again")
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		root := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		n := &Notifier{Root: root, Interval: 50 * time.Millisecond, Poll: poll, Debounce: 10 * time.Millisecond}
+		changes, events := n.Start(ctx)
+		if poll && events {
+			t.Fatal("poll mode must not use filesystem events")
+		}
+		<-changes // Initial scan
+
+		if err := os.MkdirAll(filepath.Join(root, "run", "1_A01", "metadata"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("poll=%v: no notification after a change", poll)
+		}
+
+		cancel()
+		for range changes {
+		}
+	}
+}